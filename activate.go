@@ -0,0 +1,154 @@
+package goonep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrInvalidCIK is returned for a CIK that is not 40 lowercase hex
+	// digits
+	ErrInvalidCIK = errors.New("goonep: invalid CIK")
+
+	// ErrNotWhitelisted is returned by ActivateDevice when the vendor,
+	// model or serial number is unknown, or the serial number was never
+	// enabled
+	ErrNotWhitelisted = errors.New("goonep: serial number is not whitelisted")
+
+	// ErrAlreadyActivated is returned by ActivateDevice when the serial
+	// number was activated before. Its CIK is only handed out once.
+	ErrAlreadyActivated = errors.New("goonep: serial number is already activated")
+
+	// ErrNoCredentials is returned by CredentialStore.Load when nothing
+	// has been saved yet
+	ErrNoCredentials = errors.New("goonep: no credentials stored")
+)
+
+// CIK is a client interface key, the credential a device authenticates
+// with
+type CIK string
+
+// ParseCIK checks that s, ignoring surrounding whitespace, is a well formed
+// CIK
+func ParseCIK(s string) (CIK, error) {
+	s = strings.TrimSpace(s)
+	if len(s) != 40 {
+		return "", fmt.Errorf("%w: %q", ErrInvalidCIK, s)
+	}
+	for _, b := range s {
+		if !strings.ContainsRune("0123456789abcdef", b) {
+			return "", fmt.Errorf("%w: %q", ErrInvalidCIK, s)
+		}
+	}
+	return CIK(s), nil
+}
+
+func (k CIK) String() string {
+	return string(k)
+}
+
+// CredentialStore persists a device's CIK across restarts
+type CredentialStore interface {
+	// Load returns the saved CIK, or ErrNoCredentials
+	Load() (CIK, error)
+
+	Save(cik CIK) error
+}
+
+// FileCredentialStore keeps the CIK in the file at Path, readable by its
+// owner only
+type FileCredentialStore struct {
+	Path string
+}
+
+// Load reads the CIK back from the file
+func (s *FileCredentialStore) Load() (CIK, error) {
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", ErrNoCredentials
+	}
+	if err != nil {
+		return "", err
+	}
+	return ParseCIK(string(data))
+}
+
+// Save replaces the file with one holding cik. The file is written next to
+// it first and renamed, so a crash never leaves half a CIK behind.
+func (s *FileCredentialStore) Save(cik CIK) error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.WriteString(string(cik) + "\n"); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.Path)
+}
+
+// ActivateDevice activates the serial number sn of a vendor's model and
+// returns the device's CIK, like Serialnumber_activate but with the CIK
+// checked. Activation fails with ErrNotWhitelisted or
+// ErrAlreadyActivated, which also wrap the *ProvisionError.
+func (c *Client) ActivateDevice(ctx context.Context, vendor, model, sn string) (CIK, error) {
+	cik, err := Serialnumber_activateContext(ctx, ProvModel{client: c}, model, sn, vendor)
+	switch {
+	case IsNotFound(err):
+		return "", fmt.Errorf("%w: %w", ErrNotWhitelisted, err)
+	case IsConflict(err):
+		return "", fmt.Errorf("%w: %w", ErrAlreadyActivated, err)
+	case err != nil:
+		return "", err
+	}
+	return ParseCIK(cik)
+}
+
+// ActivateDeviceWithStore is like ActivateDevice but returns the CIK saved
+// in store when there is one, and saves the CIK it activated otherwise
+func (c *Client) ActivateDeviceWithStore(ctx context.Context, vendor, model, sn string, store CredentialStore) (CIK, error) {
+	cik, err := store.Load()
+	if err == nil {
+		return cik, nil
+	}
+	if !errors.Is(err, ErrNoCredentials) {
+		return "", err
+	}
+
+	cik, err = c.ActivateDevice(ctx, vendor, model, sn)
+	if err != nil {
+		return "", err
+	}
+	if err := store.Save(cik); err != nil {
+		return cik, fmt.Errorf("goonep: saving CIK of %s: %w", sn, err)
+	}
+	return cik, nil
+}
+
+// the package level functions below call their Client counterparts on the
+// default client
+
+func ActivateDevice(ctx context.Context, vendor, model, sn string) (CIK, error) {
+	return defaultClient().ActivateDevice(ctx, vendor, model, sn)
+}
+
+func ActivateDeviceWithStore(ctx context.Context, vendor, model, sn string, store CredentialStore) (CIK, error) {
+	return defaultClient().ActivateDeviceWithStore(ctx, vendor, model, sn, store)
+}
@@ -0,0 +1,75 @@
+package goonep
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestActivateDevice(t *testing.T) {
+	setupProvision(t)
+	ctx := context.Background()
+	var model = "MyActivatedModel" + strconv.Itoa(rand.Intn(10000000))
+
+	resp, _ := Lookup(clonecik, "alias", "")
+	if err := Model_create(ProvModel{}, vendortoken, model, resp.Results[0].Body.(string), true, true, true); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	defer Model_remove(ProvModel{}, vendortoken, model)
+	if err := Serialnumber_add_batch(ProvModel{}, vendortoken, model, []string{"001", "002", "003"}); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	portal, _ := Lookup(portalcik, "alias", "")
+	for _, sn := range []string{"001", "002"} {
+		if err := Serialnumber_enable(ProvModel{}, vendortoken, model, sn, portal.Results[0].Body.(string)); err != nil {
+			t.Fatalf("Failed: %v", err)
+		}
+	}
+
+	cik, err := ActivateDevice(ctx, vendorname, model, "001")
+	if err != nil || !validCikRid(cik.String()) {
+		t.Errorf("Failed: unexpected CIK %q %v", cik, err)
+	}
+	_, err = ActivateDevice(ctx, vendorname, model, "001")
+	if !errors.Is(err, ErrAlreadyActivated) || !IsConflict(err) {
+		t.Errorf("Failed: expected already activated, got %v", err)
+	}
+	for _, sn := range []string{"003", "004"} {
+		if _, err := ActivateDevice(ctx, vendorname, model, sn); !errors.Is(err, ErrNotWhitelisted) {
+			t.Errorf("Failed: expected %s not whitelisted, got %v", sn, err)
+		}
+	}
+
+	store := &FileCredentialStore{Path: filepath.Join(t.TempDir(), "cik")}
+	if _, err := store.Load(); err != ErrNoCredentials {
+		t.Errorf("Failed: expected no credentials, got %v", err)
+	}
+	stored, err := ActivateDeviceWithStore(ctx, vendorname, model, "002", store)
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	// a restarted agent gets the saved CIK instead of a conflict
+	again, err := ActivateDeviceWithStore(ctx, vendorname, model, "002", store)
+	if err != nil || again != stored {
+		t.Errorf("Failed: expected saved CIK %q, got %q %v", stored, again, err)
+	}
+	stat, err := os.Stat(store.Path)
+	if err != nil || stat.Mode().Perm() != 0600 {
+		t.Errorf("Failed: unexpected credential file %v %v", stat, err)
+	}
+}
+
+func TestParseCIK(t *testing.T) {
+	if cik, err := ParseCIK(" 0123456789abcdef0123456789abcdef01234567\n"); err != nil || len(cik) != 40 {
+		t.Errorf("Failed: %q %v", cik, err)
+	}
+	for _, s := range []string{"", "0123456789ABCDEF0123456789ABCDEF01234567", "0123456789abcdef", "HTTP/1.1 404 Not Found"} {
+		if _, err := ParseCIK(s); !errors.Is(err, ErrInvalidCIK) {
+			t.Errorf("Failed: %q should be invalid, got %v", s, err)
+		}
+	}
+}
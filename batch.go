@@ -0,0 +1,300 @@
+package goonep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrNotDone is returned by Future.Result before its batch has been sent
+var ErrNotDone = errors.New("goonep: batch has not been sent")
+
+// Batch collects calls that are sent to the server in a single request.
+// Each call added returns a Future that holds the call's result once the
+// batch has been sent with Do.
+//
+//	b := goonep.NewBatch(cik)
+//	temp := b.Read(tempRID, map[string]interface{}{})
+//	humidity := b.Read(humidityRID, map[string]interface{}{})
+//	if _, err := b.Do(); err != nil {
+//		return err
+//	}
+//	result, err := temp.Result()
+type Batch struct {
+	client  *Client
+	auth    interface{}
+	calls   []interface{}
+	futures []*Future
+}
+
+// Future is the pending result of one call in a Batch
+type Future struct {
+	id        int
+	procedure string
+	result    Result
+	err       error
+	done      bool
+}
+
+// NewBatch starts an empty batch of calls made with auth
+func (c *Client) NewBatch(auth interface{}) *Batch {
+	return &Batch{client: c, auth: auth}
+}
+
+// NewBatch starts an empty batch of calls made with auth on the default client
+func NewBatch(auth interface{}) *Batch {
+	return defaultClient().NewBatch(auth)
+}
+
+// Id returns the call id the future's call is sent with
+func (f *Future) Id() int {
+	return f.id
+}
+
+// Procedure returns the name of the future's call
+func (f *Future) Procedure() string {
+	return f.procedure
+}
+
+// Result returns the result of the call along with its error, which is
+// ErrNotDone until the batch has been sent
+func (f *Future) Result() (Result, error) {
+	if !f.done {
+		return f.result, ErrNotDone
+	}
+	return f.result, f.err
+}
+
+// Len returns the number of calls in the batch
+func (b *Batch) Len() int {
+	return len(b.calls)
+}
+
+// Add appends a call of any procedure to the batch
+func (b *Batch) Add(procedure string, arguments []interface{}) *Future {
+	future := &Future{id: len(b.calls) + 1, procedure: procedure}
+	b.calls = append(b.calls, map[string]interface{}{
+		"id":        future.id,
+		"procedure": procedure,
+		"arguments": arguments,
+	})
+	b.futures = append(b.futures, future)
+	return future
+}
+
+// Do sends all calls in one request and resolves their futures. The error
+// is only set when the request as a whole failed, in which case every
+// future holds that error too; failures of single calls are reported
+// through their futures.
+func (b *Batch) Do() (Response, error) {
+	return b.DoContext(context.Background())
+}
+
+// DoContext is like Do but gives up when ctx is done
+func (b *Batch) DoContext(ctx context.Context) (Response, error) {
+	resp, err := b.client.CallMultiContext(ctx, b.auth, b.calls)
+	var callErr *CallError
+	if err != nil && !errors.As(err, &callErr) {
+		for _, future := range b.futures {
+			future.result = Result{}
+			future.err = err
+			future.done = true
+		}
+		return resp, err
+	}
+
+	byId := make(map[int]Result, len(resp.Results))
+	for _, result := range resp.Results {
+		byId[result.Id] = result
+	}
+	for _, future := range b.futures {
+		result, ok := byId[future.id]
+		future.result = result
+		future.done = true
+		if ok {
+			future.err = result.Err()
+		} else {
+			future.err = fmt.Errorf("goonep: no result for call %d (%s)", future.id, future.procedure)
+		}
+	}
+	return resp, nil
+}
+
+// the following methods add a call of the RPC their names correspond to
+
+func (b *Batch) Activate(codetype string, code string) *Future {
+	var arguments = []interface{}{
+		codetype,
+		code,
+	}
+	return b.Add("activate", arguments)
+}
+
+func (b *Batch) Create(ttype string, desc interface{}) *Future {
+	var arguments = []interface{}{
+		ttype,
+		desc,
+	}
+	return b.Add("create", arguments)
+}
+
+func (b *Batch) Deactivate(codetype string, code string) *Future {
+	var arguments = []interface{}{
+		codetype,
+		code,
+	}
+	return b.Add("deactivate", arguments)
+}
+
+func (b *Batch) Drop(rid interface{}) *Future {
+	var arguments = []interface{}{
+		rid,
+	}
+	return b.Add("drop", arguments)
+}
+
+func (b *Batch) Flush(rid interface{}) *Future {
+	var arguments = []interface{}{
+		rid,
+	}
+	return b.Add("flush", arguments)
+}
+
+func (b *Batch) Info(rid interface{}, options interface{}) *Future {
+	var arguments = []interface{}{
+		rid,
+		options,
+	}
+	return b.Add("info", arguments)
+}
+
+func (b *Batch) Listing(types interface{}) *Future {
+	var arguments = []interface{}{
+		types,
+	}
+	return b.Add("listing", arguments)
+}
+
+func (b *Batch) ListingWithOptions(types interface{}, options interface{}) *Future {
+	var arguments = []interface{}{
+		types,
+		options,
+	}
+	return b.Add("listing", arguments)
+}
+
+func (b *Batch) Lookup(ttype string, alias string) *Future {
+	var arguments = []interface{}{
+		ttype,
+		alias,
+	}
+	return b.Add("lookup", arguments)
+}
+
+func (b *Batch) OneMap(rid interface{}, alias string) *Future {
+	var arguments = []interface{}{
+		"alias",
+		rid,
+		alias,
+	}
+	return b.Add("map", arguments)
+}
+
+func (b *Batch) Query(rid, filter, sel, options interface{}) *Future {
+	var arguments = []interface{}{
+		rid,
+		filter,
+		sel,
+		options,
+	}
+	return b.Add("query", arguments)
+}
+
+func (b *Batch) Read(rid interface{}, options interface{}) *Future {
+	var arguments = []interface{}{
+		rid,
+		options,
+	}
+	return b.Add("read", arguments)
+}
+
+func (b *Batch) Record(rid interface{}, entries interface{}, options interface{}) *Future {
+	var arguments = []interface{}{
+		rid,
+		entries,
+		options,
+	}
+	return b.Add("record", arguments)
+}
+
+func (b *Batch) Recordbatch(rid interface{}, entries interface{}) *Future {
+	var arguments = []interface{}{
+		rid,
+		entries,
+	}
+	return b.Add("recordbatch", arguments)
+}
+
+func (b *Batch) Revoke(codetype string, code string) *Future {
+	var arguments = []interface{}{
+		codetype,
+		code,
+	}
+	return b.Add("revoke", arguments)
+}
+
+func (b *Batch) Share(rid interface{}, options interface{}) *Future {
+	var arguments = []interface{}{
+		rid,
+		options,
+	}
+	return b.Add("share", arguments)
+}
+
+func (b *Batch) Unmap(alias string) *Future {
+	var arguments = []interface{}{
+		"alias",
+		alias,
+	}
+	return b.Add("unmap", arguments)
+}
+
+func (b *Batch) Update(rid interface{}, desc interface{}) *Future {
+	var arguments = []interface{}{
+		rid,
+		desc,
+	}
+	return b.Add("update", arguments)
+}
+
+func (b *Batch) Usage(rid interface{}, metric string, starttime int, endtime string) *Future {
+	var arguments = []interface{}{
+		rid,
+		metric,
+		starttime,
+		endtime,
+	}
+	return b.Add("usage", arguments)
+}
+
+func (b *Batch) Wait(rid interface{}) *Future {
+	var arguments = []interface{}{
+		rid,
+	}
+	return b.Add("wait", arguments)
+}
+
+func (b *Batch) Write(rid interface{}, value interface{}) *Future {
+	var arguments = []interface{}{
+		rid,
+		value,
+	}
+	return b.Add("write", arguments)
+}
+
+func (b *Batch) Writegroup(entries interface{}) *Future {
+	var arguments = []interface{}{
+		entries,
+	}
+	return b.Add("writegroup", arguments)
+}
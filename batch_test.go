@@ -0,0 +1,52 @@
+package goonep
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestBatch(t *testing.T) {
+	client := replyClient(t, http.StatusOK, `[{"id":3,"status":"invalid"},{"id":2,"status":"ok"},{"id":1,"status":"ok","result":[[1400000000,42]]}]`)
+
+	b := client.NewBatch("cik")
+	read := b.Read("rid1", map[string]interface{}{})
+	write := b.Write("rid2", 7)
+	info := b.Info("rid3", map[string]interface{}{})
+	missing := b.Flush("rid4")
+
+	if _, err := read.Result(); err != ErrNotDone {
+		t.Errorf("Expected ErrNotDone, got %v", err)
+	}
+	if b.Len() != 4 {
+		t.Errorf("Expected 4 calls, got %d", b.Len())
+	}
+
+	if _, err := b.Do(); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	result, err := read.Result()
+	if err != nil || result.Id != 1 || result.Body == nil {
+		t.Errorf("Unexpected read result: %v %v", result, err)
+	}
+	if _, err := write.Result(); err != nil {
+		t.Errorf("Unexpected write error: %v", err)
+	}
+	if _, err := info.Result(); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Expected ErrInvalid, got %v", err)
+	}
+	if _, err := missing.Result(); err == nil {
+		t.Errorf("Expected error for call without result")
+	}
+
+	client = replyClient(t, http.StatusOK, `{"error":{"code":401,"message":"Unauthorized","context":"auth"}}`)
+	b = client.NewBatch("cik")
+	read = b.Read("rid1", map[string]interface{}{})
+	if _, err := b.Do(); !errors.Is(err, ErrNoAuth) {
+		t.Errorf("Expected ErrNoAuth, got %v", err)
+	}
+	if _, err := read.Result(); !errors.Is(err, ErrNoAuth) {
+		t.Errorf("Expected ErrNoAuth on future, got %v", err)
+	}
+}
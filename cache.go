@@ -0,0 +1,259 @@
+package goonep
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// ModelCache holds the serial numbers looked up by ProvModel.FindSerialNumber,
+// keyed by provisioning host, vendor key, model and serial number. Serial
+// numbers that do not exist are cached too, so repeated lookups of unknown
+// devices do not reach the server. It is safe for concurrent use.
+type ModelCache struct {
+	// TTL is how long a found serial number is kept. Zero keeps it until
+	// it is evicted or invalidated.
+	TTL time.Duration
+
+	// NegativeTTL is how long a serial number that was not found is kept.
+	// Zero means TTL.
+	NegativeTTL time.Duration
+
+	// MaxSize bounds the number of serial numbers held. The least recently
+	// used one is evicted to make room. Zero means no bound.
+	MaxSize int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // most recently used first
+	stats   ModelCacheStats
+	now     func() time.Time
+
+	// generation counts invalidations, so that lookups that were in
+	// flight during one do not cache what they fetched
+	generation uint64
+}
+
+// ModelCacheStats counts the lookups of a ModelCache
+type ModelCacheStats struct {
+	Hits      int64
+	Misses    int64
+	Evictions int64
+	Size      int
+}
+
+// CacheKey is a serial number of a model on a provisioning host, as looked
+// up with a vendor's key. Clients talking to different hosts or as
+// different vendors never see each other's entries.
+type CacheKey struct {
+	Host   string
+	Vendor string
+	Model  string
+	SN     string
+}
+
+// cacheEntry holds what each vendor looked up for one serial number
+type cacheEntry struct {
+	key     string
+	host    string
+	model   string
+	vendors map[string]cacheValue
+}
+
+type cacheValue struct {
+	model   ProvModel
+	found   bool
+	expires time.Time
+}
+
+// NewModelCache returns an empty cache keeping entries for ttl and holding
+// at most maxSize of them
+func NewModelCache(ttl time.Duration, maxSize int) *ModelCache {
+	return &ModelCache{TTL: ttl, MaxSize: maxSize}
+}
+
+// defaultModelCache is shared by the clients the package level functions
+// build
+var defaultModelCache = NewModelCache(5*time.Minute, 10000)
+
+func cacheKey(host, model, sn string) string {
+	return host + "\x00" + model + "\x00" + sn
+}
+
+// init sets up the internal state of a cache built without NewModelCache
+func (c *ModelCache) init() {
+	if c.entries == nil {
+		c.entries = map[string]*list.Element{}
+		c.lru = list.New()
+	}
+	if c.now == nil {
+		c.now = time.Now
+	}
+}
+
+// Get returns the cached serial number of key. The first bool tells
+// whether the serial number exists, the second whether the cache had an
+// answer at all.
+func (c *ModelCache) Get(key CacheKey) (ProvModel, bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+
+	elem := c.entries[cacheKey(key.Host, key.Model, key.SN)]
+	if elem == nil {
+		c.stats.Misses++
+		return ProvModel{}, false, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	value, ok := entry.vendors[key.Vendor]
+	if !ok {
+		c.stats.Misses++
+		return ProvModel{}, false, false
+	}
+	if !value.expires.IsZero() && !c.now().Before(value.expires) {
+		delete(entry.vendors, key.Vendor)
+		if len(entry.vendors) == 0 {
+			c.remove(elem)
+		}
+		c.stats.Misses++
+		return ProvModel{}, false, false
+	}
+	c.lru.MoveToFront(elem)
+	c.stats.Hits++
+	return value.model, value.found, true
+}
+
+// Add caches the serial number of key, or that it does not exist when
+// found is false
+func (c *ModelCache) Add(key CacheKey, m ProvModel, found bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+	c.add(key, m, found)
+}
+
+// Lookup returns the cached serial number of key, or calls fetch and
+// caches what it returns. Errors are not cached. What fetch returns while
+// the cache is invalidated is returned but not cached, so an invalidation
+// is never undone by a lookup that was in flight.
+func (c *ModelCache) Lookup(key CacheKey, fetch func() (ProvModel, bool, error)) (ProvModel, bool, error) {
+	c.mu.Lock()
+	generation := c.generation
+	c.mu.Unlock()
+
+	if m, found, ok := c.Get(key); ok {
+		return m, found, nil
+	}
+	m, found, err := fetch()
+	if err != nil {
+		return m, found, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+	if c.generation == generation {
+		c.add(key, m, found)
+	}
+	return m, found, nil
+}
+
+func (c *ModelCache) add(key CacheKey, m ProvModel, found bool) {
+	ttl := c.TTL
+	if !found && c.NegativeTTL > 0 {
+		ttl = c.NegativeTTL
+	}
+	value := cacheValue{model: m, found: found}
+	if ttl > 0 {
+		value.expires = c.now().Add(ttl)
+	}
+
+	k := cacheKey(key.Host, key.Model, key.SN)
+	if elem := c.entries[k]; elem != nil {
+		elem.Value.(*cacheEntry).vendors[key.Vendor] = value
+		c.lru.MoveToFront(elem)
+		return
+	}
+	entry := &cacheEntry{key: k, host: key.Host, model: key.Model, vendors: map[string]cacheValue{key.Vendor: value}}
+	c.entries[k] = c.lru.PushFront(entry)
+	for c.MaxSize > 0 && c.lru.Len() > c.MaxSize {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+// Invalidate drops the serial number sn of model on host, as cached for
+// any vendor, e.g. after it was enabled or removed
+func (c *ModelCache) Invalidate(host, model, sn string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+
+	c.generation++
+	if elem := c.entries[cacheKey(host, model, sn)]; elem != nil {
+		c.remove(elem)
+	}
+}
+
+// InvalidateModel drops every cached serial number of model on host
+func (c *ModelCache) InvalidateModel(host, model string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+
+	c.generation++
+	for elem := c.lru.Front(); elem != nil; {
+		next := elem.Next()
+		if entry := elem.Value.(*cacheEntry); entry.host == host && entry.model == model {
+			c.remove(elem)
+		}
+		elem = next
+	}
+}
+
+// Purge drops every entry. The statistics are kept.
+func (c *ModelCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.entries = nil
+	c.lru = nil
+	c.init()
+}
+
+// Stats returns the cache's counters
+func (c *ModelCache) Stats() ModelCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+
+	stats := c.stats
+	stats.Size = c.lru.Len()
+	return stats
+}
+
+func (c *ModelCache) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*cacheEntry).key)
+}
+
+// invalidate drops serial numbers that are being changed from the cache of
+// the model's client
+func (m ProvModel) invalidate(model string, sns ...string) {
+	client := m.provClient()
+	if cache := client.Models; cache != nil {
+		for _, sn := range sns {
+			cache.Invalidate(client.provisionHost(), model, sn)
+		}
+	}
+}
+
+// invalidateModel drops the serial numbers of a model that is being removed
+// from the cache of the model's client
+func (m ProvModel) invalidateModel(model string) {
+	client := m.provClient()
+	if cache := client.Models; cache != nil {
+		cache.InvalidateModel(client.provisionHost(), model)
+	}
+}
@@ -0,0 +1,182 @@
+package goonep
+
+import (
+	"math/rand"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestModelCache(t *testing.T) {
+	now := time.Unix(1400000000, 0)
+	cache := NewModelCache(time.Minute, 2)
+	cache.NegativeTTL = time.Second
+	cache.now = func() time.Time { return now }
+	key := func(model, sn string) CacheKey {
+		return CacheKey{Host: "h", Vendor: "v", Model: model, SN: sn}
+	}
+
+	if _, _, ok := cache.Get(key("m", "001")); ok {
+		t.Errorf("Failed: empty cache had an entry")
+	}
+	cache.Add(key("m", "001"), ProvModel{SN: "001"}, true)
+	cache.Add(key("m", "002"), ProvModel{}, false)
+	if m, found, ok := cache.Get(key("m", "001")); !ok || !found || m.SN != "001" {
+		t.Errorf("Failed: unexpected entry %v %v %v", m, found, ok)
+	}
+	if _, found, ok := cache.Get(key("m", "002")); !ok || found {
+		t.Errorf("Failed: expected a negative entry, got %v %v", found, ok)
+	}
+
+	// the negative entry expires first
+	now = now.Add(2 * time.Second)
+	if _, _, ok := cache.Get(key("m", "002")); ok {
+		t.Errorf("Failed: negative entry did not expire")
+	}
+
+	// 001 is used after 003 was added, so 004 evicts 003
+	cache.Add(key("m", "003"), ProvModel{}, true)
+	if _, _, ok := cache.Get(key("m", "001")); !ok {
+		t.Errorf("Failed: entry expired early")
+	}
+	cache.Add(key("m", "004"), ProvModel{}, true)
+	if _, _, ok := cache.Get(key("m", "003")); ok {
+		t.Errorf("Failed: least recently used entry was kept")
+	}
+	stats := cache.Stats()
+	if stats.Size != 2 || stats.Evictions != 1 || stats.Hits != 3 || stats.Misses != 3 {
+		t.Errorf("Failed: unexpected stats %+v", stats)
+	}
+
+	cache.Invalidate("h", "m", "004")
+	if _, _, ok := cache.Get(key("m", "004")); ok {
+		t.Errorf("Failed: invalidated entry was kept")
+	}
+	cache.Add(key("other", "001"), ProvModel{}, true)
+	cache.InvalidateModel("h", "m")
+	if _, _, ok := cache.Get(key("other", "001")); !ok {
+		t.Errorf("Failed: entry of another model was invalidated")
+	}
+	cache.Purge()
+	if stats := cache.Stats(); stats.Size != 0 {
+		t.Errorf("Failed: purge kept %d entries", stats.Size)
+	}
+
+	now = now.Add(time.Hour)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				sn := strconv.Itoa(j % 5)
+				cache.Add(key("m", sn), ProvModel{SN: sn}, true)
+				cache.Get(key("m", sn))
+				if j%10 == 0 {
+					cache.Invalidate("h", "m", sn)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if stats := cache.Stats(); stats.Size > 2 {
+		t.Errorf("Failed: cache grew to %d entries", stats.Size)
+	}
+}
+
+func TestModelCacheScope(t *testing.T) {
+	cache := NewModelCache(time.Minute, 10)
+	a := CacheKey{Host: "a.example.com", Vendor: "token", Model: "m", SN: "001"}
+	b := a
+	b.Host = "b.example.com"
+	other := a
+	other.Vendor = "other"
+
+	cache.Add(a, ProvModel{Rid: "a"}, true)
+	for _, key := range []CacheKey{b, other} {
+		if m, _, ok := cache.Get(key); ok {
+			t.Errorf("Failed: %+v got the entry of %+v: %+v", key, a, m)
+		}
+	}
+	cache.Add(other, ProvModel{Rid: "other"}, true)
+	if m, _, _ := cache.Get(a); m.Rid != "a" {
+		t.Errorf("Failed: entry was replaced by another vendor's: %+v", m)
+	}
+
+	// invalidating on one host drops the serial number for every vendor
+	cache.Add(b, ProvModel{Rid: "b"}, true)
+	cache.Invalidate(a.Host, a.Model, a.SN)
+	for _, key := range []CacheKey{a, other} {
+		if _, _, ok := cache.Get(key); ok {
+			t.Errorf("Failed: %+v was not invalidated", key)
+		}
+	}
+	if _, _, ok := cache.Get(b); !ok {
+		t.Errorf("Failed: entry of another host was invalidated")
+	}
+
+	// a lookup in flight while the serial number is invalidated does not
+	// put it back
+	m, found, err := cache.Lookup(a, func() (ProvModel, bool, error) {
+		cache.Invalidate(a.Host, a.Model, a.SN)
+		return ProvModel{Rid: "stale"}, true, nil
+	})
+	if err != nil || !found || m.Rid != "stale" {
+		t.Errorf("Failed: unexpected lookup %+v %v %v", m, found, err)
+	}
+	if m, _, ok := cache.Get(a); ok {
+		t.Errorf("Failed: stale lookup was cached: %+v", m)
+	}
+	m, _, _ = cache.Lookup(a, func() (ProvModel, bool, error) {
+		return ProvModel{Rid: "fresh"}, true, nil
+	})
+	if cached, _, ok := cache.Get(a); !ok || cached.Rid != "fresh" || m.Rid != "fresh" {
+		t.Errorf("Failed: lookup was not cached: %+v", cached)
+	}
+}
+
+func TestFindSerialNumberCache(t *testing.T) {
+	setupProvision(t)
+	client := *DefaultClient
+	client.VendorToken = vendortoken
+	client.Models = NewModelCache(time.Minute, 10)
+	prov := ProvModel{client: &client}
+
+	var model = "MyCachedModel" + strconv.Itoa(rand.Intn(10000000))
+	resp, _ := Lookup(clonecik, "alias", "")
+	if err := Model_create(prov, vendortoken, model, resp.Results[0].Body.(string), true, true, true); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	defer Model_remove(prov, vendortoken, model)
+
+	finder := prov
+	if found := finder.FindSerialNumber(model, "001"); found.SN != "" {
+		t.Errorf("Failed: found unknown serial number %+v", found)
+	}
+	if found := finder.FindSerialNumber(model, "001"); found.SN != "" {
+		t.Errorf("Failed: found unknown serial number %+v", found)
+	}
+	if stats := client.Models.Stats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("Failed: 404 was not cached %+v", stats)
+	}
+
+	// adding the serial number invalidates the negative entry
+	if err := Serialnumber_add(prov, vendortoken, model, "001"); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	portal, _ := Lookup(portalcik, "alias", "")
+	if err := Serialnumber_enable(prov, vendortoken, model, "001", portal.Results[0].Body.(string)); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	found := finder.FindSerialNumber(model, "001")
+	if found.SN != "001" || found.ActiveStatus != "notactivated" || !found.Validate() {
+		t.Errorf("Failed: unexpected serial number %+v", found)
+	}
+	if cached := finder.Find(model, "001"); cached.Rid != found.Rid {
+		t.Errorf("Failed: unexpected cached serial number %+v", cached)
+	}
+	if stats := client.Models.Stats(); stats.Hits != 2 || stats.Misses != 2 {
+		t.Errorf("Failed: unexpected stats %+v", stats)
+	}
+}
@@ -0,0 +1,254 @@
+package goonep
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sync"
+)
+
+// Limits bounds the size of a single RPC request. Calls that do not fit are
+// split over several requests whose results are merged back into one
+// Response, keeping the ids the calls were sent with. Zero fields mean no
+// limit.
+type Limits struct {
+	// MaxCalls is the number of calls sent in one request.
+	MaxCalls int
+
+	// MaxPoints is the number of data points sent in one request, counted
+	// over write, writegroup, record and recordbatch calls. Recordbatch
+	// calls with more entries, including those of a Batch or CallMulti, are
+	// split into several calls and reported as one result with the id they
+	// were made with.
+	MaxPoints int
+
+	// MaxBytes is the JSON encoded size of the calls sent in one request.
+	MaxBytes int
+
+	// Parallel is the number of requests sent at once when calls have been
+	// split. Zero or one sends them one after the other.
+	Parallel int
+}
+
+func (l Limits) enabled() bool {
+	return l.MaxCalls > 0 || l.MaxPoints > 0 || l.MaxBytes > 0
+}
+
+// split groups calls into chunks that each fit the limits. A call that
+// exceeds the limits by itself is sent in a chunk of its own.
+func (l Limits) split(calls []interface{}) ([][]interface{}, error) {
+	var chunks [][]interface{}
+	var chunk []interface{}
+	var points, size int
+
+	for _, call := range calls {
+		buf, err := json.Marshal(call)
+		if err != nil {
+			return nil, err
+		}
+		callPoints := countPoints(buf)
+
+		full := (l.MaxCalls > 0 && len(chunk) >= l.MaxCalls) ||
+			(l.MaxPoints > 0 && points+callPoints > l.MaxPoints) ||
+			(l.MaxBytes > 0 && size+len(buf) > l.MaxBytes)
+		if full && len(chunk) > 0 {
+			chunks = append(chunks, chunk)
+			chunk, points, size = nil, 0, 0
+		}
+
+		chunk = append(chunk, call)
+		points += callPoints
+		size += len(buf) + 1
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
+// countPoints returns the number of data points an encoded call carries
+func countPoints(call []byte) int {
+	var decoded struct {
+		Procedure string            `json:"procedure"`
+		Arguments []json.RawMessage `json:"arguments"`
+	}
+	if json.Unmarshal(call, &decoded) != nil {
+		return 0
+	}
+
+	var index int
+	switch decoded.Procedure {
+	case "write":
+		return 1
+	case "writegroup":
+		index = 0
+	case "record", "recordbatch":
+		index = 1
+	default:
+		return 0
+	}
+	if len(decoded.Arguments) <= index {
+		return 0
+	}
+	var entries []json.RawMessage
+	if json.Unmarshal(decoded.Arguments[index], &entries) != nil {
+		return 0
+	}
+	return len(entries)
+}
+
+// postChunks sends each chunk in a request of its own, at most
+// Limits.Parallel at a time, and merges the results in chunk order. No new
+// chunks are sent once a request has failed as a whole.
+func (c *Client) postChunks(ctx context.Context, auth interface{}, chunks [][]interface{}) (Response, error) {
+	parallel := c.Limits.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	responses := make([]Response, len(chunks))
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	sem := make(chan struct{}, parallel)
+
+	failed := func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		return firstErr
+	}
+
+	for i, chunk := range chunks {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil || failed() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, chunk []interface{}) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			resp, err := c.post(ctx, auth, chunk)
+			responses[i] = resp
+			var callErr *CallError
+			if err != nil && !errors.As(err, &callErr) {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}(i, chunk)
+	}
+	wg.Wait()
+
+	merged := Response{}
+	for _, resp := range responses {
+		merged.Results = append(merged.Results, resp.Results...)
+	}
+	if firstErr != nil {
+		return merged, firstErr
+	}
+	if ctx.Err() != nil {
+		return merged, ctx.Err()
+	}
+	return merged, merged.Err()
+}
+
+// splitEntries cuts a slice of entries into parts of at most max entries.
+// Anything that is not a slice is returned as a single part.
+func splitEntries(entries interface{}, max int) []interface{} {
+	value := reflect.ValueOf(entries)
+	if value.Kind() != reflect.Slice || value.Len() <= max {
+		return []interface{}{entries}
+	}
+
+	var parts []interface{}
+	for start := 0; start < value.Len(); start += max {
+		end := start + max
+		if end > value.Len() {
+			end = value.Len()
+		}
+		parts = append(parts, value.Slice(start, end).Interface())
+	}
+	return parts
+}
+
+// splitRecordbatches replaces each recordbatch call with more entries than
+// MaxPoints by calls recording a part each. The parts are sent with ids
+// past those of calls; the map returned holds the id of the call each part
+// came from.
+func (l Limits) splitRecordbatches(calls []interface{}) ([]interface{}, map[int]int) {
+	if l.MaxPoints <= 0 {
+		return calls, nil
+	}
+	next := 0
+	for _, call := range calls {
+		if call, ok := call.(map[string]interface{}); ok {
+			if id, ok := call["id"].(int); ok && id > next {
+				next = id
+			}
+		}
+	}
+
+	var split []interface{}
+	var parts map[int]int
+	for _, call := range calls {
+		m, _ := call.(map[string]interface{})
+		id, ok := m["id"].(int)
+		arguments, _ := m["arguments"].([]interface{})
+		if !ok || m["procedure"] != "recordbatch" || len(arguments) != 2 {
+			split = append(split, call)
+			continue
+		}
+		entries := splitEntries(arguments[1], l.MaxPoints)
+		if len(entries) == 1 {
+			split = append(split, call)
+			continue
+		}
+		if parts == nil {
+			parts = map[int]int{}
+		}
+		for _, part := range entries {
+			next++
+			parts[next] = id
+			split = append(split, map[string]interface{}{
+				"id":        next,
+				"procedure": "recordbatch",
+				"arguments": []interface{}{arguments[0], part},
+			})
+		}
+	}
+	return split, parts
+}
+
+// mergeRecordbatches reports the parts made by splitRecordbatches as the
+// call they came from, in the place of its first part. The call fails with
+// its first failed part.
+func mergeRecordbatches(resp Response, parts map[int]int) Response {
+	merged := Response{}
+	index := map[int]int{}
+	for _, result := range resp.Results {
+		id, ok := parts[result.Id]
+		if !ok {
+			merged.Results = append(merged.Results, result)
+			continue
+		}
+		i, seen := index[id]
+		if !seen {
+			i = len(merged.Results)
+			index[id] = i
+			merged.Results = append(merged.Results, Result{Id: id, Status: "ok"})
+		}
+		if merged.Results[i].Err() == nil && result.Err() != nil {
+			result.Id = id
+			merged.Results[i] = result
+		}
+	}
+	return merged
+}
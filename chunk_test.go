@@ -0,0 +1,123 @@
+package goonep
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// echoClient returns a client whose server answers every call with status
+// ok, along with a function reporting the number of calls per request
+func echoClient(t *testing.T) (*Client, func() []int) {
+	var mu sync.Mutex
+	var requests []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Calls []struct {
+				Id int `json:"id"`
+			} `json:"calls"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		mu.Lock()
+		requests = append(requests, len(request.Calls))
+		mu.Unlock()
+
+		var results []map[string]interface{}
+		for _, call := range request.Calls {
+			results = append(results, map[string]interface{}{"id": call.Id, "status": "ok"})
+		}
+		json.NewEncoder(w).Encode(results)
+	}))
+	t.Cleanup(server.Close)
+	client := &Client{Scheme: "http", Host: strings.TrimPrefix(server.URL, "http://")}
+	return client, func() []int {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]int(nil), requests...)
+	}
+}
+
+func TestChunkedCalls(t *testing.T) {
+	client, requests := echoClient(t)
+	client.Limits = Limits{MaxCalls: 3, Parallel: 2}
+
+	b := client.NewBatch("cik")
+	var futures []*Future
+	for i := 0; i < 8; i++ {
+		futures = append(futures, b.Read("rid", map[string]interface{}{}))
+	}
+	resp, err := b.Do()
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if len(resp.Results) != 8 {
+		t.Errorf("Expected 8 merged results, got %d", len(resp.Results))
+	}
+	for i, future := range futures {
+		result, err := future.Result()
+		if err != nil || result.Id != i+1 {
+			t.Errorf("Unexpected result for call %d: %v %v", i+1, result, err)
+		}
+	}
+	if got := requests(); len(got) != 3 {
+		t.Errorf("Expected 3 requests, got %v", got)
+	}
+}
+
+func TestChunkedRecordbatch(t *testing.T) {
+	client, requests := echoClient(t)
+	client.Limits = Limits{MaxPoints: 10}
+
+	var entries [][]interface{}
+	for i := 0; i < 25; i++ {
+		entries = append(entries, []interface{}{1400000000 + i, []interface{}{[]interface{}{"temp", i}}})
+	}
+	resp, err := client.Recordbatch("cik", "rid", entries)
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if len(resp.Results) != 1 || resp.Results[0].Id != 1 || resp.Results[0].Status != "ok" {
+		t.Errorf("Unexpected merged result: %v", resp.Results)
+	}
+	if got := requests(); len(got) != 3 {
+		t.Errorf("Expected 3 requests, got %v", got)
+	}
+}
+
+func TestChunkedRecordbatchInBatch(t *testing.T) {
+	client, requests := echoClient(t)
+	client.Limits = Limits{MaxPoints: 10}
+
+	var entries [][]interface{}
+	for i := 0; i < 25; i++ {
+		entries = append(entries, []interface{}{1400000000 + i, i})
+	}
+	b := client.NewBatch("cik")
+	read := b.Read("rid", map[string]interface{}{})
+	record := b.Recordbatch("rid", entries)
+	resp, err := b.Do()
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if len(resp.Results) != 2 {
+		t.Errorf("Expected 2 merged results, got %v", resp.Results)
+	}
+	for _, future := range []*Future{read, record} {
+		if result, err := future.Result(); err != nil || result.Id != future.Id() {
+			t.Errorf("Unexpected result for call %d: %v %v", future.Id(), result, err)
+		}
+	}
+	if got := requests(); len(got) != 3 {
+		t.Errorf("Expected 3 requests, got %v", got)
+	}
+
+	// the caller's ids are kept
+	calls := []interface{}{map[string]interface{}{"id": 7, "procedure": "recordbatch", "arguments": []interface{}{"rid", entries}}}
+	resp, err = client.CallMulti("cik", calls)
+	if err != nil || len(resp.Results) != 1 || resp.Results[0].Id != 7 {
+		t.Errorf("Unexpected merged result: %v %v", resp.Results, err)
+	}
+}
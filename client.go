@@ -0,0 +1,204 @@
+package goonep
+
+import (
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// RPC_PATH is the path of the JSON RPC endpoint, relative to a client's BasePath.
+var RPC_PATH = "/onep:v1/rpc/process"
+
+// Client holds everything needed to talk to one One Platform instance.
+// A zero Client is not usable; create one with NewClient or fill in at
+// least Host. Clients are safe for concurrent use as long as their fields
+// are not modified after first use.
+type Client struct {
+	// Scheme is either "https" or "http". Empty means "https".
+	Scheme string
+
+	// Host is e.g. "m2.exosite.com" or "localhost:18393".
+	Host string
+
+	// BasePath is prepended to every API path, for servers that are
+	// mounted below the root of their host.
+	BasePath string
+
+	// ProvisionScheme and ProvisionHost point the provisioning API at a
+	// different server. Empty means Scheme and Host.
+	ProvisionScheme string
+	ProvisionHost   string
+
+	// HTTPClient carries out the requests. Nil means http.DefaultClient,
+	// or a client using TLSConfig when that is set.
+	HTTPClient *http.Client
+
+	// TLSConfig is used for HTTPS requests when HTTPClient is nil, e.g. to
+	// trust a private CA. Each request then opens a connection of its own;
+	// set HTTPClient instead to reuse connections.
+	TLSConfig *tls.Config
+
+	// UserAgent is sent with every request. Empty means "goonep <version>".
+	UserAgent string
+
+	// Auth is used by calls made with a nil auth argument.
+	Auth interface{}
+
+	// VendorToken authenticates provisioning management requests made
+	// through the client. Empty means the package level VendorToken.
+	VendorToken string
+
+	// Retry decides which failed requests are sent again. Nil means every
+	// request is sent once.
+	Retry *RetryPolicy
+
+	// Limits bounds the size of a single RPC request. Larger call lists
+	// are split over several requests.
+	Limits Limits
+
+	// Models caches the serial numbers ProvModel.FindSerialNumber looks up
+	// through the client. Nil turns caching off.
+	Models *ModelCache
+}
+
+// NewClient returns a Client talking HTTPS to host that retries idempotent
+// requests with DefaultRetryPolicy and caches serial number lookups for five
+// minutes.
+func NewClient(host string) *Client {
+	return &Client{
+		Scheme: "https",
+		Host:   host,
+		Retry:  DefaultRetryPolicy,
+		Models: NewModelCache(5*time.Minute, 10000),
+	}
+}
+
+// DefaultClient is used by the package level functions. When it is nil
+// they build a client from ONEPHost and InDev on every call, which keeps
+// code that sets those globals working.
+var DefaultClient *Client
+
+// defaultClient returns the client used by the package level functions.
+// Without a DefaultClient, provisioning always uses HTTPS and the clients
+// built share one ModelCache.
+func defaultClient() *Client {
+	if DefaultClient != nil {
+		return DefaultClient
+	}
+	if InDev {
+		return &Client{Scheme: "https", Host: "m2-dev.exosite.com", Retry: DefaultRetryPolicy, Models: defaultModelCache}
+	}
+	return &Client{Scheme: "http", Host: ONEPHost, ProvisionScheme: "https", Retry: DefaultRetryPolicy, Models: defaultModelCache}
+}
+
+// url builds the full URL of an API path on this client's server
+func (c *Client) url(path string) string {
+	scheme := c.Scheme
+	if scheme == "" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Host + c.BasePath + path
+}
+
+// provisionURL builds the full URL of a provisioning API path
+func (c *Client) provisionURL(path string) string {
+	scheme := c.ProvisionScheme
+	if scheme == "" {
+		scheme = c.Scheme
+	}
+	if scheme == "" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.provisionHost() + c.BasePath + path
+}
+
+// provisionHost returns the host of the provisioning API
+func (c *Client) provisionHost() string {
+	if c.ProvisionHost != "" {
+		return c.ProvisionHost
+	}
+	return c.Host
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	if c.TLSConfig != nil {
+		// nothing outlives the request, so no connection pool is left
+		// behind
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = c.TLSConfig
+		transport.DisableKeepAlives = true
+		return &http.Client{Transport: transport}
+	}
+	return http.DefaultClient
+}
+
+func (c *Client) userAgent() string {
+	if c.UserAgent != "" {
+		return c.UserAgent
+	}
+	return "goonep " + version
+}
+
+// fullAuth expands auth into the object the RPC expects, falling back to
+// the client's default auth when auth is nil
+func (c *Client) fullAuth(auth interface{}) interface{} {
+	if auth == nil {
+		auth = c.Auth
+	}
+	if cik, ok := auth.(string); ok {
+		return map[string]interface{}{"cik": cik}
+	}
+	return auth
+}
+
+// do sends req with ctx and reads the whole response body, retrying as
+// the client's RetryPolicy allows. Requests that are not idempotent are
+// only retried when the policy says so. Once ctx is done the error
+// returned is ctx.Err() rather than the transport's error.
+func (c *Client) do(ctx context.Context, req *http.Request, idempotent bool) (*http.Response, []byte, error) {
+	req.Header.Set("User-Agent", c.userAgent())
+
+	policy := c.Retry
+	for attempt := 1; ; attempt++ {
+		resp, body, err := c.doOnce(ctx, req)
+		if ctx.Err() != nil {
+			return resp, nil, ctx.Err()
+		}
+		if !policy.shouldRetry(req, attempt, idempotent, resp, err) {
+			return resp, body, err
+		}
+		if err := policy.wait(ctx, attempt, resp); err != nil {
+			return resp, nil, err
+		}
+	}
+}
+
+// doOnce makes a single attempt at req
+func (c *Client) doOnce(ctx context.Context, req *http.Request) (*http.Response, []byte, error) {
+	attempt := req.Clone(ctx)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, nil, err
+		}
+		attempt.Body = body
+	}
+
+	resp, err := c.httpClient().Do(attempt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return resp, nil, err
+	}
+	return resp, body, nil
+}
@@ -0,0 +1,114 @@
+package goonep
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientRequest(t *testing.T) {
+	var gotPath, gotAgent string
+	var gotBody map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotAgent = r.Header.Get("User-Agent")
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Write([]byte(`[{"id":1,"status":"ok","result":"0123456789012345678901234567890123456789"}]`))
+	}))
+	defer server.Close()
+
+	client := &Client{
+		Scheme:    "http",
+		Host:      strings.TrimPrefix(server.URL, "http://"),
+		BasePath:  "/api",
+		UserAgent: "tester",
+		Auth:      "defaultcik",
+	}
+
+	body, err := client.Lookup(nil, "alias", "")
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if body.Results[0].Body != "0123456789012345678901234567890123456789" {
+		t.Errorf("Unexpected result: %v", body.Results[0].Body)
+	}
+	if gotPath != "/api/onep:v1/rpc/process" {
+		t.Errorf("Unexpected path: %s", gotPath)
+	}
+	if gotAgent != "tester" {
+		t.Errorf("Unexpected user agent: %s", gotAgent)
+	}
+	auth, _ := gotBody["auth"].(map[string]interface{})
+	if auth["cik"] != "defaultcik" {
+		t.Errorf("Default auth not used: %v", gotBody["auth"])
+	}
+}
+
+func TestClientContext(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := &Client{Scheme: "http", Host: strings.TrimPrefix(server.URL, "http://")}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := client.WaitContext(ctx, "cik", "rid")
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected %v, got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestClientProvisionHost(t *testing.T) {
+	var rpcCalls int
+	rpcServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rpcCalls++
+		w.Write([]byte(`[{"id":1,"status":"ok"}]`))
+	}))
+	defer rpcServer.Close()
+
+	var gotPath, gotToken string
+	provServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotToken = r.Header.Get("X-Exosite-Token")
+		w.Write([]byte("model1\r\nmodel2\r\n"))
+	}))
+	defer provServer.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(provServer.Certificate())
+	client := &Client{
+		Scheme:          "http",
+		Host:            strings.TrimPrefix(rpcServer.URL, "http://"),
+		ProvisionScheme: "https",
+		ProvisionHost:   strings.TrimPrefix(provServer.URL, "https://"),
+		TLSConfig:       &tls.Config{RootCAs: roots},
+	}
+
+	body, err := client.ProvCall(PROVISION_MANAGE_MODEL, "token", "", "GET", false, http.Header{})
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if names := ParseList(body.([]byte)); len(names) != 2 {
+		t.Errorf("Unexpected models: %v", names)
+	}
+	if gotPath != PROVISION_MANAGE_MODEL || gotToken != "token" {
+		t.Errorf("Unexpected request: %s %s", gotPath, gotToken)
+	}
+
+	if _, err := client.Lookup("cik", "alias", ""); err != nil {
+		t.Errorf("Failed: %v", err)
+	}
+	if rpcCalls != 1 {
+		t.Errorf("RPC did not go to Host")
+	}
+}
@@ -0,0 +1,255 @@
+package goonep
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ErrChecksum is returned when downloaded content does not match the
+// expected SHA-256 or size
+var ErrChecksum = errors.New("goonep: content checksum mismatch")
+
+// DownloadOptions tune ContentDownloadWithOptions
+type DownloadOptions struct {
+	// Offset resumes an earlier download: the first Offset bytes are not
+	// fetched again, w only receives the rest.
+	Offset int64
+
+	// Partial holds the Offset bytes downloaded before, when resuming
+	// with a SHA256 to verify. It is only read.
+	Partial io.Reader
+
+	// SHA256 is the expected hex encoded checksum of the whole content.
+	// Empty means the checksum is not verified.
+	SHA256 string
+}
+
+// vendorToken returns the token provisioning management calls are made with
+func (c *Client) vendorToken() string {
+	if c.VendorToken != "" {
+		return c.VendorToken
+	}
+	return VendorToken
+}
+
+// provModel returns the ProvModel managing by the client's vendor token
+func (c *Client) provModel() ProvModel {
+	return ProvModel{client: c, key: c.vendorToken()}
+}
+
+// hashingReader hashes and counts what is read through it
+type hashingReader struct {
+	r    io.Reader
+	hash hash.Hash
+	n    int64
+}
+
+func (h *hashingReader) Read(p []byte) (int, error) {
+	n, err := h.r.Read(p)
+	h.hash.Write(p[:n])
+	h.n += int64(n)
+	return n, err
+}
+
+// ContentUpload streams size bytes of content from r to the content item id
+// of model and returns their hex encoded SHA-256. The upload is checked
+// against the size the server reports afterwards. It is only retried when
+// r is an io.Seeker, from the position r had at the start.
+func (c *Client) ContentUpload(ctx context.Context, model, id string, r io.Reader, size int64, mime string) (string, error) {
+	return c.provModel().contentUpload(ctx, model, id, r, size, mime)
+}
+
+// contentUpload is ContentUpload authenticated the way m is managed
+func (m ProvModel) contentUpload(ctx context.Context, model, id string, r io.Reader, size int64, mime string) (string, error) {
+	c := m.provClient()
+	var path = PROVISION_MANAGE_CONTENT + url.PathEscape(model) + "/" + url.PathEscape(id)
+
+	body := &hashingReader{r: r, hash: sha256.New()}
+	req, err := http.NewRequest("POST", c.provisionURL(path), body)
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+	idempotent := false
+	if seeker, ok := r.(io.Seeker); ok {
+		start, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return "", err
+		}
+		idempotent = true
+		req.GetBody = func() (io.ReadCloser, error) {
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return nil, err
+			}
+			body = &hashingReader{r: r, hash: sha256.New()}
+			return ioutil.NopCloser(body), nil
+		}
+	}
+	setProvisionAuth(req.Header, m.key, m.managebycik)
+	req.Header.Set("Content-Type", mime)
+
+	resp, respBody, err := c.do(ctx, req, idempotent)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", &ProvisionError{StatusCode: resp.StatusCode, Method: "POST", Path: path, Body: string(respBody)}
+	}
+	if body.n != size {
+		return "", fmt.Errorf("goonep: uploaded %d bytes of %s, expected %d", body.n, id, size)
+	}
+
+	info, err := Content_infoContext(ctx, m, "", model, id, "")
+	if err != nil {
+		return "", err
+	}
+	if info.Size != size {
+		return "", fmt.Errorf("%w: server has %d bytes of %s, uploaded %d", ErrChecksum, info.Size, id, size)
+	}
+	return hex.EncodeToString(body.hash.Sum(nil)), nil
+}
+
+// ContentDownload streams the content item id of a vendor's model to w and
+// returns its hex encoded SHA-256. It authenticates with the client's Auth,
+// which must be the CIK of a device of the model. A download interrupted
+// by a network failure is resumed where it stopped, as often as the
+// client's RetryPolicy allows.
+func (c *Client) ContentDownload(ctx context.Context, vendor, model, id string, w io.Writer) (string, error) {
+	return c.ContentDownloadWithOptions(ctx, vendor, model, id, w, DownloadOptions{})
+}
+
+// ContentDownloadWithOptions is like ContentDownload but can resume an
+// earlier download and verify the checksum of the content
+func (c *Client) ContentDownloadWithOptions(ctx context.Context, vendor, model, id string, w io.Writer, opts DownloadOptions) (string, error) {
+	cik := c.dataCIK("")
+	if cik == "" {
+		return "", fmt.Errorf("goonep: downloading content needs the client's Auth to be a CIK")
+	}
+
+	sum := sha256.New()
+	if opts.Offset > 0 && opts.SHA256 != "" {
+		if opts.Partial == nil {
+			return "", fmt.Errorf("goonep: resuming a verified download needs the partial content")
+		}
+		n, err := io.CopyN(sum, opts.Partial, opts.Offset)
+		if err != nil {
+			return "", fmt.Errorf("goonep: reading partial content after %d bytes: %w", n, err)
+		}
+	}
+
+	var params = url.Values{}
+	params.Set("vendor", vendor)
+	params.Set("model", model)
+	params.Set("id", id)
+	var path = PROVISION_DOWNLOAD + "?" + params.Encode()
+
+	offset, total := opts.Offset, int64(-1)
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequest("GET", c.provisionURL(path), nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("X-Exosite-CIK", cik)
+		if offset > 0 {
+			req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+		}
+
+		var resp *http.Response
+		var n int64
+		resp, n, total, err = c.downloadOnce(ctx, req, path, offset, total, io.MultiWriter(w, sum))
+		offset += n
+		if err == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		var provErr *ProvisionError
+		if errors.As(err, &provErr) && provErr.StatusCode == http.StatusRequestedRangeNotSatisfiable && total < 0 {
+			// resuming a download that was already complete
+			break
+		}
+		if !c.Retry.shouldRetry(req, attempt, true, resp, err) {
+			return "", err
+		}
+		if err := c.Retry.wait(ctx, attempt, resp); err != nil {
+			return "", err
+		}
+	}
+
+	if total >= 0 && offset != total {
+		return "", fmt.Errorf("%w: got %d bytes of %s, expected %d", ErrChecksum, offset, id, total)
+	}
+	digest := hex.EncodeToString(sum.Sum(nil))
+	if opts.SHA256 != "" && !strings.EqualFold(opts.SHA256, digest) {
+		return "", fmt.Errorf("%w: %s has SHA-256 %s, expected %s", ErrChecksum, id, digest, opts.SHA256)
+	}
+	return digest, nil
+}
+
+// downloadOnce makes one attempt at downloading from offset and copies the
+// response body to w. It returns the number of bytes written and the size
+// of the whole content when the server told it. A server that ignores the
+// range has the bytes before offset skipped.
+func (c *Client) downloadOnce(ctx context.Context, req *http.Request, path string, offset, total int64, w io.Writer) (*http.Response, int64, int64, error) {
+	req.Header.Set("User-Agent", c.userAgent())
+	resp, err := c.httpClient().Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, 0, total, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if resp.ContentLength >= 0 {
+			total = resp.ContentLength
+		}
+		if offset > 0 {
+			if _, err := io.CopyN(ioutil.Discard, resp.Body, offset); err != nil {
+				return resp, 0, total, err
+			}
+		}
+	case http.StatusPartialContent:
+		// Content-Range: bytes <first>-<last>/<total>
+		contentRange := resp.Header.Get("Content-Range")
+		if slash := strings.LastIndex(contentRange, "/"); slash >= 0 {
+			if n, err := strconv.ParseInt(contentRange[slash+1:], 10, 64); err == nil {
+				total = n
+			}
+		}
+	default:
+		body, _ := ioutil.ReadAll(resp.Body)
+		return resp, 0, total, &ProvisionError{StatusCode: resp.StatusCode, Method: "GET", Path: path, Body: string(body)}
+	}
+
+	n, err := io.Copy(w, resp.Body)
+	return resp, n, total, err
+}
+
+// the package level functions below call their Client counterparts on the
+// default client
+
+func ContentUpload(ctx context.Context, model, id string, r io.Reader, size int64, mime string) (string, error) {
+	return defaultClient().ContentUpload(ctx, model, id, r, size, mime)
+}
+
+func ContentDownload(ctx context.Context, vendor, model, id string, w io.Writer) (string, error) {
+	return defaultClient().ContentDownload(ctx, vendor, model, id, w)
+}
+
+func ContentDownloadWithOptions(ctx context.Context, vendor, model, id string, w io.Writer, opts DownloadOptions) (string, error) {
+	return defaultClient().ContentDownloadWithOptions(ctx, vendor, model, id, w, opts)
+}
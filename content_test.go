@@ -0,0 +1,106 @@
+package goonep
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// abortingServer serves fake, but cuts off the first content download
+// halfway through
+func abortingServer(t *testing.T) *httptest.Server {
+	var aborted bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != PROVISION_DOWNLOAD || aborted {
+			fake.ServeHTTP(w, r)
+			return
+		}
+		aborted = true
+		rec := httptest.NewRecorder()
+		fake.ServeHTTP(rec, r)
+		for k, v := range rec.Header() {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(rec.Code)
+		w.Write(rec.Body.Bytes()[:rec.Body.Len()/2])
+		panic(http.ErrAbortHandler)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestContentStreaming(t *testing.T) {
+	setupProvision(t)
+	ctx := context.Background()
+	var model = "MyFirmwareModel" + strconv.Itoa(rand.Intn(10000000))
+
+	resp, _ := Lookup(clonecik, "alias", "")
+	if err := Model_create(ProvModel{}, vendortoken, model, resp.Results[0].Body.(string), true, true, true); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	defer Model_remove(ProvModel{}, vendortoken, model)
+	if err := Content_create(ProvModel{}, vendortoken, model, "fw.bin", "firmware", false); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	server := abortingServer(t)
+	client := &Client{
+		Scheme:      "http",
+		Host:        strings.TrimPrefix(server.URL, "http://"),
+		VendorToken: vendortoken,
+		Auth:        genCik(),
+		Retry:       &RetryPolicy{MaxAttempts: 3},
+	}
+
+	data := make([]byte, 1<<20)
+	rand.Read(data)
+	data[0] = 0 // binary content must survive untouched
+	digest := sha256.Sum256(data)
+	want := hex.EncodeToString(digest[:])
+
+	sum, err := client.ContentUpload(ctx, model, "fw.bin", bytes.NewReader(data), int64(len(data)), "application/octet-stream")
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if sum != want {
+		t.Errorf("Failed: upload sum %s, expected %s", sum, want)
+	}
+
+	// the first attempt is cut off and resumed with a range request
+	var buf bytes.Buffer
+	sum, err = client.ContentDownload(ctx, vendorname, model, "fw.bin", &buf)
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if sum != want || !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("Failed: downloaded %d bytes with sum %s", buf.Len(), sum)
+	}
+
+	buf.Reset()
+	sum, err = client.ContentDownloadWithOptions(ctx, vendorname, model, "fw.bin", &buf, DownloadOptions{
+		Offset:  1000,
+		Partial: bytes.NewReader(data[:1000]),
+		SHA256:  want,
+	})
+	if err != nil || sum != want || !bytes.Equal(buf.Bytes(), data[1000:]) {
+		t.Errorf("Failed: resumed download got %d bytes, %v", buf.Len(), err)
+	}
+
+	_, err = client.ContentDownloadWithOptions(ctx, vendorname, model, "fw.bin", &buf, DownloadOptions{SHA256: strings.Repeat("0", 64)})
+	if !errors.Is(err, ErrChecksum) {
+		t.Errorf("Failed: expected ErrChecksum, got %v", err)
+	}
+
+	_, err = client.ContentUpload(ctx, model, "missing.bin", strings.NewReader("x"), 1, "text/plain")
+	if !IsNotFound(err) {
+		t.Errorf("Failed: expected not found, got %v", err)
+	}
+}
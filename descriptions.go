@@ -0,0 +1,315 @@
+package goonep
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Retention bounds how much history a dataport, datarule or dispatch
+// keeps. Zero means "infinity" for both fields.
+type Retention struct {
+	// Count is the number of points kept
+	Count int64
+
+	// Duration is the number of hours points are kept for
+	Duration int64
+}
+
+func (r Retention) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"count":    infinity(r.Count),
+		"duration": infinity(r.Duration),
+	})
+}
+
+func (r *Retention) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Count    interface{} `json:"count"`
+		Duration interface{} `json:"duration"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	var err error
+	if r.Count, err = finite(raw.Count); err != nil {
+		return err
+	}
+	r.Duration, err = finite(raw.Duration)
+	return err
+}
+
+func infinity(n int64) interface{} {
+	if n == 0 {
+		return "infinity"
+	}
+	return n
+}
+
+func finite(v interface{}) (int64, error) {
+	switch v := v.(type) {
+	case nil:
+		return 0, nil
+	case string:
+		if v == "infinity" {
+			return 0, nil
+		}
+	case float64:
+		return int64(v), nil
+	}
+	return 0, fmt.Errorf("goonep: unexpected retention value %v", v)
+}
+
+// Preprocess is one step applied to values before they are stored. Op is
+// one of "add", "sub", "mul", "div", "mod", "gt", "geq", "lt", "leq", "eq",
+// "neq" or "value".
+type Preprocess struct {
+	Op    string
+	Value float64
+}
+
+func (p Preprocess) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{p.Op, p.Value})
+}
+
+func (p *Preprocess) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if len(raw) != 2 {
+		return fmt.Errorf("goonep: unexpected preprocess step %s", data)
+	}
+	if err := json.Unmarshal(raw[0], &p.Op); err != nil {
+		return err
+	}
+	return json.Unmarshal(raw[1], &p.Value)
+}
+
+// PreprocessList is sent as an empty list rather than null when empty
+type PreprocessList []Preprocess
+
+func (l PreprocessList) MarshalJSON() ([]byte, error) {
+	if l == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]Preprocess(l))
+}
+
+// DataportDesc describes a dataport for create and update
+type DataportDesc struct {
+	Format     string         `json:"format"` // "float", "integer" or "string"
+	Meta       string         `json:"meta"`
+	Name       string         `json:"name"`
+	Preprocess PreprocessList `json:"preprocess"`
+	Public     bool           `json:"public"`
+	Retention  Retention      `json:"retention"`
+	Subscribe  string         `json:"subscribe,omitempty"`
+}
+
+// SimpleRule triggers when a value compares to Constant. Comparison is one
+// of "gt", "lt", "eq", "geq", "leq" or "neq".
+type SimpleRule struct {
+	Comparison string  `json:"comparison"`
+	Constant   float64 `json:"constant"`
+	Repeat     bool    `json:"repeat"`
+}
+
+// TimeoutRule triggers when no value arrives for Timeout seconds
+type TimeoutRule struct {
+	Repeat  bool `json:"repeat"`
+	Timeout int  `json:"timeout"`
+}
+
+// IntervalRule triggers when values compare to Constant throughout an
+// interval of Timeout seconds
+type IntervalRule struct {
+	Comparison string  `json:"comparison"`
+	Constant   float64 `json:"constant"`
+	Repeat     bool    `json:"repeat"`
+	Timeout    int     `json:"timeout"`
+}
+
+// DurationRule triggers when values keep comparing to Constant for Timeout
+// seconds
+type DurationRule struct {
+	Comparison string  `json:"comparison"`
+	Constant   float64 `json:"constant"`
+	Repeat     bool    `json:"repeat"`
+	Timeout    int     `json:"timeout"`
+}
+
+// Rule is the rule of a datarule. Exactly one of its fields should be set.
+type Rule struct {
+	Simple   *SimpleRule   `json:"simple,omitempty"`
+	Timeout  *TimeoutRule  `json:"timeout,omitempty"`
+	Interval *IntervalRule `json:"interval,omitempty"`
+	Duration *DurationRule `json:"duration,omitempty"`
+
+	// Script is the Lua source of a script datarule
+	Script string `json:"script,omitempty"`
+}
+
+// DataruleDesc describes a datarule for create and update
+type DataruleDesc struct {
+	Format     string         `json:"format"`
+	Meta       string         `json:"meta"`
+	Name       string         `json:"name"`
+	Preprocess PreprocessList `json:"preprocess"`
+	Public     bool           `json:"public"`
+	Retention  Retention      `json:"retention"`
+	Rule       Rule           `json:"rule"`
+	Subscribe  string         `json:"subscribe,omitempty"`
+}
+
+// DispatchDesc describes a dispatch for create and update. Method is one of
+// "email", "http_get", "http_post", "http_put", "sms" or "xmpp".
+type DispatchDesc struct {
+	Locked     bool           `json:"locked"`
+	Message    string         `json:"message"`
+	Meta       string         `json:"meta"`
+	Method     string         `json:"method"`
+	Name       string         `json:"name"`
+	Preprocess PreprocessList `json:"preprocess"`
+	Recipient  string         `json:"recipient"`
+	Retention  Retention      `json:"retention"`
+	Subject    string         `json:"subject"`
+	Subscribe  string         `json:"subscribe,omitempty"`
+}
+
+// Limit is the number of resources of one kind a client may own. The zero
+// value shares the owner's limit; use LimitNone for a limit of zero.
+type Limit int
+
+const (
+	// LimitInherit makes a client share its owner's limit
+	LimitInherit Limit = 0
+
+	// LimitNone allows a client none of a kind of resource
+	LimitNone Limit = -1
+)
+
+func (l Limit) MarshalJSON() ([]byte, error) {
+	switch l {
+	case LimitInherit:
+		return []byte(`"inherit"`), nil
+	case LimitNone:
+		return []byte("0"), nil
+	}
+	return json.Marshal(int(l))
+}
+
+func (l *Limit) UnmarshalJSON(data []byte) error {
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		if s != "inherit" {
+			return fmt.Errorf("goonep: unexpected limit %s", data)
+		}
+		*l = LimitInherit
+		return nil
+	}
+	var n int
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	*l = Limit(n)
+	if n == 0 {
+		*l = LimitNone
+	}
+	return nil
+}
+
+// ClientLimits are the limits of a client. Fields left unset share the
+// owner's limits, so ClientLimits{} inherits all of them.
+type ClientLimits struct {
+	Client      Limit `json:"client"`
+	Dataport    Limit `json:"dataport"`
+	Datarule    Limit `json:"datarule"`
+	Disk        Limit `json:"disk"`
+	Dispatch    Limit `json:"dispatch"`
+	Email       Limit `json:"email"`
+	EmailBucket Limit `json:"email_bucket"`
+	Http        Limit `json:"http"`
+	HttpBucket  Limit `json:"http_bucket"`
+	Share       Limit `json:"share"`
+	Sms         Limit `json:"sms"`
+	SmsBucket   Limit `json:"sms_bucket"`
+	Xmpp        Limit `json:"xmpp"`
+	XmppBucket  Limit `json:"xmpp_bucket"`
+}
+
+// ClientDesc describes a client for create and update
+type ClientDesc struct {
+	Limits ClientLimits `json:"limits"`
+	Locked bool         `json:"locked"`
+	Meta   string       `json:"meta"`
+	Name   string       `json:"name"`
+	Public bool         `json:"public"`
+}
+
+// the following functions create a resource of the type their names
+// correspond to
+
+func (c *Client) CreateDataport(auth interface{}, desc DataportDesc) (Response, error) {
+	return c.CreateContext(context.Background(), auth, "dataport", desc)
+}
+
+func (c *Client) CreateDataportContext(ctx context.Context, auth interface{}, desc DataportDesc) (Response, error) {
+	return c.CreateContext(ctx, auth, "dataport", desc)
+}
+
+func (c *Client) CreateDatarule(auth interface{}, desc DataruleDesc) (Response, error) {
+	return c.CreateContext(context.Background(), auth, "datarule", desc)
+}
+
+func (c *Client) CreateDataruleContext(ctx context.Context, auth interface{}, desc DataruleDesc) (Response, error) {
+	return c.CreateContext(ctx, auth, "datarule", desc)
+}
+
+func (c *Client) CreateDispatch(auth interface{}, desc DispatchDesc) (Response, error) {
+	return c.CreateContext(context.Background(), auth, "dispatch", desc)
+}
+
+func (c *Client) CreateDispatchContext(ctx context.Context, auth interface{}, desc DispatchDesc) (Response, error) {
+	return c.CreateContext(ctx, auth, "dispatch", desc)
+}
+
+func (c *Client) CreateClient(auth interface{}, desc ClientDesc) (Response, error) {
+	return c.CreateContext(context.Background(), auth, "client", desc)
+}
+
+func (c *Client) CreateClientContext(ctx context.Context, auth interface{}, desc ClientDesc) (Response, error) {
+	return c.CreateContext(ctx, auth, "client", desc)
+}
+
+func CreateDataport(auth interface{}, desc DataportDesc) (Response, error) {
+	return defaultClient().CreateDataport(auth, desc)
+}
+
+func CreateDataportContext(ctx context.Context, auth interface{}, desc DataportDesc) (Response, error) {
+	return defaultClient().CreateDataportContext(ctx, auth, desc)
+}
+
+func CreateDatarule(auth interface{}, desc DataruleDesc) (Response, error) {
+	return defaultClient().CreateDatarule(auth, desc)
+}
+
+func CreateDataruleContext(ctx context.Context, auth interface{}, desc DataruleDesc) (Response, error) {
+	return defaultClient().CreateDataruleContext(ctx, auth, desc)
+}
+
+func CreateDispatch(auth interface{}, desc DispatchDesc) (Response, error) {
+	return defaultClient().CreateDispatch(auth, desc)
+}
+
+func CreateDispatchContext(ctx context.Context, auth interface{}, desc DispatchDesc) (Response, error) {
+	return defaultClient().CreateDispatchContext(ctx, auth, desc)
+}
+
+func CreateClient(auth interface{}, desc ClientDesc) (Response, error) {
+	return defaultClient().CreateClient(auth, desc)
+}
+
+func CreateClientContext(ctx context.Context, auth interface{}, desc ClientDesc) (Response, error) {
+	return defaultClient().CreateClientContext(ctx, auth, desc)
+}
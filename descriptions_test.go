@@ -0,0 +1,53 @@
+package goonep
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDescriptions(t *testing.T) {
+	buf, err := json.Marshal(DataportDesc{Format: "integer", Name: "who is me"})
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	expected := `{"format":"integer","meta":"","name":"who is me","preprocess":[],"public":false,"retention":{"count":"infinity","duration":"infinity"}}`
+	if string(buf) != expected {
+		t.Errorf("Unexpected dataport description: %s", buf)
+	}
+
+	rule := DataruleDesc{
+		Format:     "float",
+		Name:       "too hot",
+		Preprocess: PreprocessList{{Op: "mul", Value: 1.8}, {Op: "add", Value: 32}},
+		Retention:  Retention{Count: 100, Duration: 24},
+		Rule:       Rule{Simple: &SimpleRule{Comparison: "gt", Constant: 100}},
+	}
+	buf, err = json.Marshal(rule)
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	var decoded DataruleDesc
+	if err := json.Unmarshal(buf, &decoded); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if decoded.Retention != rule.Retention || len(decoded.Preprocess) != 2 || decoded.Preprocess[0] != rule.Preprocess[0] || decoded.Rule.Simple == nil || *decoded.Rule.Simple != *rule.Rule.Simple {
+		t.Errorf("Round trip mismatch: %s decoded to %+v", buf, decoded)
+	}
+
+	client := ClientDesc{Name: "device"}
+	client.Limits.Dataport = 10
+	client.Limits.Dispatch = LimitNone
+	buf, err = json.Marshal(client)
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	var limits map[string]map[string]interface{}
+	json.Unmarshal(buf, &limits)
+	if limits["limits"]["client"] != "inherit" || limits["limits"]["dataport"] != 10.0 || limits["limits"]["dispatch"] != 0.0 {
+		t.Errorf("Unexpected client description: %s", buf)
+	}
+	var decodedClient ClientDesc
+	if err := json.Unmarshal(buf, &decodedClient); err != nil || decodedClient.Limits != client.Limits {
+		t.Errorf("Round trip mismatch: %s decoded to %+v: %v", buf, decodedClient, err)
+	}
+}
@@ -0,0 +1,148 @@
+package goonep
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Sentinel errors for the statuses the One Platform reports. Use them with
+// errors.Is on errors returned by the RPC functions:
+//
+//	if errors.Is(err, goonep.ErrInvalid) {
+//		// the alias does not exist yet
+//	}
+var (
+	ErrInvalid    = errors.New("goonep: invalid")
+	ErrBadArg     = errors.New("goonep: badarg")
+	ErrRestricted = errors.New("goonep: restricted")
+	ErrNoAuth     = errors.New("goonep: noauth")
+)
+
+var statusErrors = map[string]error{
+	"invalid":    ErrInvalid,
+	"badarg":     ErrBadArg,
+	"restricted": ErrRestricted,
+	"noauth":     ErrNoAuth,
+}
+
+// RPCError is an error object sent by the server. It is returned on its own
+// when the whole request was refused, e.g. because of bad auth, and is
+// wrapped in a CallError when a single call failed.
+type RPCError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Context interface{} `json:"context,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	if e.Context != nil {
+		return fmt.Sprintf("goonep: rpc error %d %s (%v)", e.Code, e.Message, e.Context)
+	}
+	return fmt.Sprintf("goonep: rpc error %d %s", e.Code, e.Message)
+}
+
+// Is reports auth failures as ErrNoAuth
+func (e *RPCError) Is(target error) bool {
+	return target == ErrNoAuth && (e.Code == 401 || e.Context == "auth")
+}
+
+// CallError is the failure of a single call in a request
+type CallError struct {
+	Id     int
+	Status string
+
+	// Err is set when the server answered the call with an error object
+	// instead of a status.
+	Err *RPCError
+}
+
+func (e *CallError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("goonep: call %d failed: %v", e.Id, e.Err)
+	}
+	return fmt.Sprintf("goonep: call %d failed with status %s", e.Id, e.Status)
+}
+
+// Is matches the sentinel error for the call's status
+func (e *CallError) Is(target error) bool {
+	return target != nil && statusErrors[e.Status] == target
+}
+
+func (e *CallError) Unwrap() error {
+	if e.Err == nil {
+		return nil
+	}
+	return e.Err
+}
+
+// ProvisionError is a non-2xx response of the provisioning API
+type ProvisionError struct {
+	StatusCode int
+	Method     string
+	Path       string
+
+	// Body is what the server sent, usually just the status line
+	Body string
+}
+
+func (e *ProvisionError) Error() string {
+	return fmt.Sprintf("goonep: provisioning %s %s: %d %s", e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Is reports 401 and 403 responses as ErrNoAuth
+func (e *ProvisionError) Is(target error) bool {
+	return target == ErrNoAuth && (e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden)
+}
+
+func provisionStatus(err error, code int) bool {
+	var provErr *ProvisionError
+	return errors.As(err, &provErr) && provErr.StatusCode == code
+}
+
+// IsNotFound reports whether err is a provisioning 404, e.g. for an unknown
+// model or serial number
+func IsNotFound(err error) bool {
+	return provisionStatus(err, http.StatusNotFound)
+}
+
+// IsConflict reports whether err is a provisioning 409, e.g. for a model
+// or serial number that already exists
+func IsConflict(err error) bool {
+	return provisionStatus(err, http.StatusConflict)
+}
+
+// IsPreconditionFailed reports whether err is a provisioning 412, e.g. for
+// a clone source that does not exist
+func IsPreconditionFailed(err error) bool {
+	return provisionStatus(err, http.StatusPreconditionFailed)
+}
+
+// IsUnauthorized reports whether err is a provisioning 401
+func IsUnauthorized(err error) bool {
+	return provisionStatus(err, http.StatusUnauthorized)
+}
+
+// Err returns a *CallError when the call failed, nil otherwise
+func (r Result) Err() error {
+	if r.Error.Code != 0 || r.Error.Message != "" {
+		rpcErr := r.Error
+		return &CallError{Id: r.Id, Status: r.Status, Err: &rpcErr}
+	}
+	if r.Status == "" || r.Status == "ok" {
+		return nil
+	}
+	return &CallError{Id: r.Id, Status: r.Status}
+}
+
+// Err joins the errors of all failed calls, or returns nil when every call
+// succeeded
+func (f Response) Err() error {
+	var errs []error
+	for _, result := range f.Results {
+		if err := result.Err(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
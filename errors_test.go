@@ -0,0 +1,59 @@
+package goonep
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// replyClient returns a client whose server always answers with reply
+func replyClient(t *testing.T, status int, reply string) *Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(reply))
+	}))
+	t.Cleanup(server.Close)
+	return &Client{Scheme: "http", Host: strings.TrimPrefix(server.URL, "http://")}
+}
+
+func TestRPCErrors(t *testing.T) {
+	client := replyClient(t, http.StatusOK, `{"error":{"code":401,"message":"Unauthorized","context":"auth"}}`)
+	_, err := client.Read("badcik", "rid", map[string]interface{}{})
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != 401 {
+		t.Errorf("Expected *RPCError with code 401, got %v", err)
+	}
+	if !errors.Is(err, ErrNoAuth) {
+		t.Errorf("Expected ErrNoAuth, got %v", err)
+	}
+
+	client = replyClient(t, http.StatusOK, `[{"id":1,"status":"ok"},{"id":2,"status":"badarg"},{"id":3,"status":"restricted"}]`)
+	body, err := client.CallMulti("cik", []interface{}{})
+	if len(body.Results) != 3 {
+		t.Fatalf("Expected 3 results, got %v", body.Results)
+	}
+	if !errors.Is(err, ErrBadArg) || !errors.Is(err, ErrRestricted) || errors.Is(err, ErrInvalid) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	var callErr *CallError
+	if !errors.As(body.Results[1].Err(), &callErr) || callErr.Id != 2 || callErr.Status != "badarg" {
+		t.Errorf("Unexpected call error: %v", body.Results[1].Err())
+	}
+	if body.Results[0].Err() != nil {
+		t.Errorf("Unexpected error for ok call: %v", body.Results[0].Err())
+	}
+
+	client = replyClient(t, http.StatusOK, `[{"id":1,"error":{"code":400,"message":"Bad Request","context":"arguments"}}]`)
+	_, err = client.Read("cik", "rid", map[string]interface{}{})
+	if !errors.As(err, &callErr) || callErr.Err == nil || callErr.Err.Message != "Bad Request" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	client = replyClient(t, http.StatusServiceUnavailable, "down for maintenance")
+	_, err = client.Read("cik", "rid", map[string]interface{}{})
+	if !errors.As(err, &rpcErr) || rpcErr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
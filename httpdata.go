@@ -0,0 +1,248 @@
+// HTTP Data Interface
+// http://docs.exosite.com/http/
+package goonep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var STACK_ALIAS_PATH = "/onep:v1/stack/alias"
+var TIMESTAMP_PATH = "/timestamp"
+
+// ErrNotModified is returned by DataWait when no new value arrived before
+// the timeout
+var ErrNotModified = errors.New("goonep: not modified")
+
+// HTTPError is an unexpected HTTP status returned by the HTTP Data
+// Interface
+type HTTPError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("goonep: http status %d %s", e.StatusCode, strings.TrimSpace(e.Body))
+}
+
+// dataCIK falls back to the client's default auth when cik is empty
+func (c *Client) dataCIK(cik string) string {
+	if cik == "" {
+		cik, _ = c.Auth.(string)
+	}
+	return cik
+}
+
+// dataCall carries out a request on the HTTP Data Interface. Values are
+// sent form encoded in the body and aliases are asked for in the query.
+func (c *Client) dataCall(ctx context.Context, method, path, cik string, values map[string]string, aliases []string, extra_headers http.Header, idempotent bool) (*http.Response, []byte, error) {
+	serverUrl := c.url(path)
+	if strings.HasPrefix(path, PROVISION_BASE) {
+		serverUrl = c.provisionURL(path)
+	}
+	if len(aliases) > 0 {
+		query := make([]string, len(aliases))
+		for i, alias := range aliases {
+			query[i] = url.QueryEscape(alias)
+		}
+		serverUrl += "?" + strings.Join(query, "&")
+	}
+
+	form := url.Values{}
+	for alias, value := range values {
+		form.Set(alias, value)
+	}
+	req, err := http.NewRequest(method, serverUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, nil, err
+	}
+	for key, value := range extra_headers {
+		req.Header[key] = value
+	}
+	if cik != "" {
+		req.Header.Set("X-Exosite-CIK", cik)
+	}
+	if method == "POST" {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	}
+	req.Header.Set("Accept", "application/x-www-form-urlencoded; charset=utf-8")
+
+	resp, body, err := c.do(ctx, req, idempotent)
+	if err != nil {
+		return resp, body, err
+	}
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotModified {
+		return resp, body, &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	return resp, body, nil
+}
+
+// parseValues decodes the alias=value pairs of a read response
+func parseValues(body []byte) (map[string]string, error) {
+	form, err := url.ParseQuery(strings.TrimSpace(string(body)))
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(form))
+	for alias := range form {
+		values[alias] = form.Get(alias)
+	}
+	return values, nil
+}
+
+// DataWrite writes values to the dataports mapped to their aliases
+func (c *Client) DataWrite(cik string, values map[string]string) error {
+	return c.DataWriteContext(context.Background(), cik, values)
+}
+
+func (c *Client) DataWriteContext(ctx context.Context, cik string, values map[string]string) error {
+	_, _, err := c.dataCall(ctx, "POST", STACK_ALIAS_PATH, c.dataCIK(cik), values, nil, nil, false)
+	return err
+}
+
+// DataRead returns the latest values of the dataports mapped to aliases.
+// Aliases without a value are missing from the result.
+func (c *Client) DataRead(cik string, aliases ...string) (map[string]string, error) {
+	return c.DataReadContext(context.Background(), cik, aliases...)
+}
+
+func (c *Client) DataReadContext(ctx context.Context, cik string, aliases ...string) (map[string]string, error) {
+	_, body, err := c.dataCall(ctx, "GET", STACK_ALIAS_PATH, c.dataCIK(cik), nil, aliases, nil, true)
+	if err != nil {
+		return nil, err
+	}
+	return parseValues(body)
+}
+
+// DataWriteRead writes values and reads aliases in a single request
+func (c *Client) DataWriteRead(cik string, values map[string]string, aliases ...string) (map[string]string, error) {
+	return c.DataWriteReadContext(context.Background(), cik, values, aliases...)
+}
+
+func (c *Client) DataWriteReadContext(ctx context.Context, cik string, values map[string]string, aliases ...string) (map[string]string, error) {
+	_, body, err := c.dataCall(ctx, "POST", STACK_ALIAS_PATH, c.dataCIK(cik), values, aliases, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	return parseValues(body)
+}
+
+// DataWait long polls alias for a value newer than since, for at most
+// timeout. It returns the value and when it was written, or ErrNotModified
+// when the timeout passed first. A zero since waits for the next value.
+func (c *Client) DataWait(cik, alias string, timeout time.Duration, since time.Time) (string, time.Time, error) {
+	return c.DataWaitContext(context.Background(), cik, alias, timeout, since)
+}
+
+func (c *Client) DataWaitContext(ctx context.Context, cik, alias string, timeout time.Duration, since time.Time) (string, time.Time, error) {
+	var headers = http.Header{}
+	headers.Set("Request-Timeout", strconv.FormatInt(int64(timeout/time.Millisecond), 10))
+	if !since.IsZero() {
+		headers.Set("If-Modified-Since", strconv.FormatInt(since.Unix(), 10))
+	}
+
+	resp, body, err := c.dataCall(ctx, "GET", STACK_ALIAS_PATH, c.dataCIK(cik), nil, []string{alias}, headers, true)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		return "", time.Time{}, ErrNotModified
+	}
+	values, err := parseValues(body)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return values[alias], parseModified(resp.Header.Get("Last-Modified")), nil
+}
+
+// parseModified reads a Last-Modified header sent as HTTP date or as unix
+// timestamp
+func parseModified(header string) time.Time {
+	if t, err := http.ParseTime(header); err == nil {
+		return t
+	}
+	if seconds, err := strconv.ParseInt(header, 10, 64); err == nil {
+		return time.Unix(seconds, 0)
+	}
+	return time.Time{}
+}
+
+// Timestamp returns the server's current unix time
+func (c *Client) Timestamp() (int64, error) {
+	return c.TimestampContext(context.Background())
+}
+
+func (c *Client) TimestampContext(ctx context.Context) (int64, error) {
+	_, body, err := c.dataCall(ctx, "GET", TIMESTAMP_PATH, "", nil, nil, nil, true)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(body)), 10, 64)
+}
+
+// DataActivate activates a device's serial number and returns its CIK. It
+// is Serialnumber_activate on this client, failing with a *ProvisionError.
+func (c *Client) DataActivate(vendor, model, sn string) (string, error) {
+	return c.DataActivateContext(context.Background(), vendor, model, sn)
+}
+
+func (c *Client) DataActivateContext(ctx context.Context, vendor, model, sn string) (string, error) {
+	return Serialnumber_activateContext(ctx, ProvModel{client: c}, model, sn, vendor)
+}
+
+// the package level functions below call their Client counterparts on the
+// default client
+
+func DataWrite(cik string, values map[string]string) error {
+	return defaultClient().DataWrite(cik, values)
+}
+
+func DataWriteContext(ctx context.Context, cik string, values map[string]string) error {
+	return defaultClient().DataWriteContext(ctx, cik, values)
+}
+
+func DataRead(cik string, aliases ...string) (map[string]string, error) {
+	return defaultClient().DataRead(cik, aliases...)
+}
+
+func DataReadContext(ctx context.Context, cik string, aliases ...string) (map[string]string, error) {
+	return defaultClient().DataReadContext(ctx, cik, aliases...)
+}
+
+func DataWriteRead(cik string, values map[string]string, aliases ...string) (map[string]string, error) {
+	return defaultClient().DataWriteRead(cik, values, aliases...)
+}
+
+func DataWriteReadContext(ctx context.Context, cik string, values map[string]string, aliases ...string) (map[string]string, error) {
+	return defaultClient().DataWriteReadContext(ctx, cik, values, aliases...)
+}
+
+func DataWait(cik, alias string, timeout time.Duration, since time.Time) (string, time.Time, error) {
+	return defaultClient().DataWait(cik, alias, timeout, since)
+}
+
+func DataWaitContext(ctx context.Context, cik, alias string, timeout time.Duration, since time.Time) (string, time.Time, error) {
+	return defaultClient().DataWaitContext(ctx, cik, alias, timeout, since)
+}
+
+func Timestamp() (int64, error) {
+	return defaultClient().Timestamp()
+}
+
+func TimestampContext(ctx context.Context) (int64, error) {
+	return defaultClient().TimestampContext(ctx)
+}
+
+func DataActivate(vendor, model, sn string) (string, error) {
+	return defaultClient().DataActivate(vendor, model, sn)
+}
+
+func DataActivateContext(ctx context.Context, vendor, model, sn string) (string, error) {
+	return defaultClient().DataActivateContext(ctx, vendor, model, sn)
+}
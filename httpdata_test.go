@@ -0,0 +1,96 @@
+package goonep
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// dataportAlias creates a string dataport under cik and maps it to alias
+func dataportAlias(t *testing.T, cik, alias string) {
+	resp, err := CreateDataport(cik, DataportDesc{Format: "string", Name: alias})
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	rid, err := resp.Results[0].RID()
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if _, err := OneMap(cik, rid, alias); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+}
+
+func TestDataWriteRead(t *testing.T) {
+	var cik = genCik()
+	dataportAlias(t, cik, alias)
+	dataportAlias(t, cik, alias2)
+
+	if err := DataWrite(cik, map[string]string{alias: "one"}); err != nil {
+		t.Errorf("Failed: %v", err)
+	}
+	values, err := DataRead(cik, alias, alias2)
+	if err != nil {
+		t.Errorf("Failed: %v", err)
+	}
+	if values[alias] != "one" {
+		t.Errorf("Failed: read %v", values)
+	}
+	if _, ok := values[alias2]; ok {
+		t.Errorf("Failed: %s has no value but read %v", alias2, values)
+	}
+
+	values, err = DataWriteRead(cik, map[string]string{alias2: "two & more"}, alias, alias2)
+	if err != nil {
+		t.Errorf("Failed: %v", err)
+	}
+	if values[alias] != "one" || values[alias2] != "two & more" {
+		t.Errorf("Failed: read %v", values)
+	}
+
+	err = DataWrite(cik, map[string]string{"unknown": "1"})
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		t.Errorf("Failed: writing an unmapped alias gave %v", err)
+	}
+}
+
+func TestDataWait(t *testing.T) {
+	var cik = genCik()
+	dataportAlias(t, cik, alias)
+
+	if _, _, err := DataWait(cik, alias, 50*time.Millisecond, time.Time{}); err != ErrNotModified {
+		t.Errorf("Failed: expected ErrNotModified, got %v", err)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		DataWrite(cik, map[string]string{alias: "woken"})
+	}()
+	value, modified, err := DataWait(cik, alias, 5*time.Second, time.Now().Add(-time.Second))
+	if err != nil {
+		t.Errorf("Failed: %v", err)
+	}
+	if value != "woken" || modified.IsZero() {
+		t.Errorf("Failed: waited for %q at %v", value, modified)
+	}
+}
+
+func TestTimestamp(t *testing.T) {
+	ts, err := Timestamp()
+	if err != nil {
+		t.Errorf("Failed: %v", err)
+	}
+	if d := time.Now().Unix() - ts; d < -5 || d > 5 {
+		t.Errorf("Failed: timestamp %d is off by %d seconds", ts, d)
+	}
+}
+
+func TestDataActivate(t *testing.T) {
+	setupProvision(t)
+	_, err := DataActivate(vendorname, "NoSuchModel", "001")
+	var provErr *ProvisionError
+	if !errors.As(err, &provErr) || !IsNotFound(err) {
+		t.Errorf("Failed: expected a not found ProvisionError, got %v", err)
+	}
+}
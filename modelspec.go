@@ -0,0 +1,165 @@
+package goonep
+
+import (
+	"context"
+	"net/url"
+)
+
+// ModelSpec is the desired state of a client model: where new devices are
+// cloned from and what is copied along
+type ModelSpec struct {
+	Name string
+
+	// Code is the share code devices are cloned from. When it is empty
+	// they are cloned from the client Rid.
+	Code string
+	Rid  string
+
+	// Aliases, Comments and Historical tell whether aliases, comments and
+	// data points are copied from the clone source
+	Aliases    bool
+	Comments   bool
+	Historical bool
+}
+
+// ModelAction is what ReconcileModel did to a model
+type ModelAction int
+
+const (
+	ModelUnchanged ModelAction = iota
+	ModelCreated
+	ModelUpdated
+)
+
+func (a ModelAction) String() string {
+	switch a {
+	case ModelCreated:
+		return "created"
+	case ModelUpdated:
+		return "updated"
+	}
+	return "unchanged"
+}
+
+// Spec returns the spec info satisfies
+func (info ModelInfo) Spec() ModelSpec {
+	return ModelSpec{
+		Name:       info.Name,
+		Code:       info.Code,
+		Rid:        info.Rid,
+		Aliases:    info.Aliases,
+		Comments:   info.Comments,
+		Historical: info.Historical,
+	}
+}
+
+// values encodes the spec for model create and update
+func (s ModelSpec) values() url.Values {
+	var params = url.Values{}
+	if s.Code != "" {
+		params.Set("code", s.Code)
+	} else {
+		params.Set("rid", s.Rid)
+	}
+	if !s.Aliases {
+		params.Add("options[]", "noaliases")
+	}
+	if !s.Comments {
+		params.Add("options[]", "nocomments")
+	}
+	if !s.Historical {
+		params.Add("options[]", "nohistorical")
+	}
+	return params
+}
+
+// Diff returns the names of the fields in which info differs from the
+// spec. A model cloned by share code reports the shared rid too, which is
+// not compared.
+func (s ModelSpec) Diff(info ModelInfo) []string {
+	var fields []string
+	if s.Code != info.Code {
+		fields = append(fields, "Code")
+	}
+	if s.Code == "" && s.Rid != info.Rid {
+		fields = append(fields, "Rid")
+	}
+	if s.Aliases != info.Aliases {
+		fields = append(fields, "Aliases")
+	}
+	if s.Comments != info.Comments {
+		fields = append(fields, "Comments")
+	}
+	if s.Historical != info.Historical {
+		fields = append(fields, "Historical")
+	}
+	return fields
+}
+
+// info is the model info satisfying the spec
+func (s ModelSpec) info() ModelInfo {
+	return ModelInfo{
+		Name:       s.Name,
+		Code:       s.Code,
+		Rid:        s.Rid,
+		Aliases:    s.Aliases,
+		Comments:   s.Comments,
+		Historical: s.Historical,
+	}
+}
+
+// Reconcile brings model spec.Name in line with spec, creating it when it
+// does not exist and updating it when it differs
+func (m *ProvModel) Reconcile(ctx context.Context, key string, spec ModelSpec) (ModelAction, error) {
+	info, err := Model_infoContext(ctx, *m, key, spec.Name)
+	if IsNotFound(err) {
+		if err := m.Create(ctx, key, spec.info()); err != nil {
+			return ModelUnchanged, err
+		}
+		return ModelCreated, nil
+	}
+	if err != nil {
+		return ModelUnchanged, err
+	}
+	if len(spec.Diff(info)) == 0 {
+		return ModelUnchanged, nil
+	}
+	if err := m.Update(ctx, key, spec.info()); err != nil {
+		return ModelUnchanged, err
+	}
+	return ModelUpdated, nil
+}
+
+// CreateModel creates the model spec.Name with ProvModel.Create
+func (c *Client) CreateModel(ctx context.Context, spec ModelSpec) error {
+	m := c.provModel()
+	return m.Create(ctx, "", spec.info())
+}
+
+// UpdateModel changes the clone source and options of model spec.Name with
+// ProvModel.Update
+func (c *Client) UpdateModel(ctx context.Context, spec ModelSpec) error {
+	m := c.provModel()
+	return m.Update(ctx, "", spec.info())
+}
+
+// ReconcileModel is ProvModel.Reconcile with the client's vendor token
+func (c *Client) ReconcileModel(ctx context.Context, spec ModelSpec) (ModelAction, error) {
+	m := c.provModel()
+	return m.Reconcile(ctx, "", spec)
+}
+
+// the package level functions below call their Client counterparts on the
+// default client
+
+func CreateModel(ctx context.Context, spec ModelSpec) error {
+	return defaultClient().CreateModel(ctx, spec)
+}
+
+func UpdateModel(ctx context.Context, spec ModelSpec) error {
+	return defaultClient().UpdateModel(ctx, spec)
+}
+
+func ReconcileModel(ctx context.Context, spec ModelSpec) (ModelAction, error) {
+	return defaultClient().ReconcileModel(ctx, spec)
+}
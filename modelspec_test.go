@@ -0,0 +1,94 @@
+package goonep
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestModelUpdate(t *testing.T) {
+	setupProvision(t)
+	var model = "MyUpdatedModel" + strconv.Itoa(rand.Intn(10000000))
+
+	resp, _ := Lookup(clonecik, "alias", "")
+	clonerid := resp.Results[0].Body.(string)
+	if err := Model_create(ProvModel{}, vendortoken, model, clonerid, true, true, true); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	defer Model_remove(ProvModel{}, vendortoken, model)
+
+	if err := Model_update(ProvModel{}, vendortoken, model, clonerid, false, true, false); err != nil {
+		t.Errorf("Failed: %v", err)
+	}
+	info, err := Model_info(ProvModel{}, vendortoken, model)
+	if err != nil || info.Aliases || !info.Comments || info.Historical {
+		t.Errorf("Failed: options were not updated %+v %v", info, err)
+	}
+
+	resp, _ = Share(portalcik, clonerid, map[string]interface{}{})
+	code := resp.Results[0].Body.(string)
+	provModel := ProvModel{managebysharecode: true}
+	if err := Model_update(provModel, vendortoken, model, code, true, true, true); err != nil {
+		t.Errorf("Failed: %v", err)
+	}
+	info, err = Model_info(provModel, vendortoken, model)
+	if err != nil || info.Code != code || !info.Aliases || !info.Historical {
+		t.Errorf("Failed: share code was not used %+v %v", info, err)
+	}
+}
+
+func TestReconcileModel(t *testing.T) {
+	setupProvision(t)
+	ctx := context.Background()
+	client := *DefaultClient
+	client.VendorToken = vendortoken
+
+	resp, _ := Lookup(clonecik, "alias", "")
+	spec := ModelSpec{
+		Name:     "MyReconciledModel" + strconv.Itoa(rand.Intn(10000000)),
+		Rid:      resp.Results[0].Body.(string),
+		Comments: true,
+	}
+	defer Model_remove(ProvModel{}, vendortoken, spec.Name)
+
+	for i, expected := range []ModelAction{ModelCreated, ModelUnchanged} {
+		action, err := client.ReconcileModel(ctx, spec)
+		if err != nil || action != expected {
+			t.Errorf("Failed: reconcile %d %v, expected %v: %v", i, action, expected, err)
+		}
+	}
+	info, err := Model_info(ProvModel{}, vendortoken, spec.Name)
+	if err != nil || !reflect.DeepEqual(info.Spec(), spec) {
+		t.Errorf("Failed: unexpected model %+v %v", info, err)
+	}
+
+	spec.Historical = true
+	if diff := spec.Diff(info); !reflect.DeepEqual(diff, []string{"Historical"}) {
+		t.Errorf("Failed: unexpected diff %v", diff)
+	}
+	if action, err := client.ReconcileModel(ctx, spec); err != nil || action != ModelUpdated {
+		t.Errorf("Failed: %v %v", action, err)
+	}
+	if info, err := Model_info(ProvModel{}, vendortoken, spec.Name); err != nil || !info.Historical || info.Aliases {
+		t.Errorf("Failed: unexpected model %+v %v", info, err)
+	}
+
+	// the ProvModel methods reconcile the same way
+	resp, _ = Share(portalcik, spec.Rid, map[string]interface{}{})
+	byCode := spec
+	byCode.Code = resp.Results[0].Body.(string)
+	prov := ProvModel{managebysharecode: true}
+	if action, err := prov.Reconcile(ctx, vendortoken, byCode); err != nil || action != ModelUpdated {
+		t.Errorf("Failed: %v %v", action, err)
+	}
+	if info, err := Model_info(prov, vendortoken, spec.Name); err != nil || info.Code != byCode.Code {
+		t.Errorf("Failed: share code was not used %+v %v", info, err)
+	}
+
+	spec.Rid = "0000000000000000000000000000000000000000"
+	if _, err := client.ReconcileModel(ctx, spec); !IsPreconditionFailed(err) {
+		t.Errorf("Failed: expected unknown clone source to fail, got %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package onepfake
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// serveStackAlias answers the HTTP Data Interface: form encoded writes in
+// the body, reads of the aliases named in the query, and long polls when
+// a Request-Timeout header is sent
+func (s *Server) serveStackAlias(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	writes, err := url.ParseQuery(string(body))
+	if err != nil {
+		provisionError(w, http.StatusBadRequest)
+		return
+	}
+	reads, _ := url.ParseQuery(r.URL.RawQuery)
+
+	s.mu.Lock()
+	client := s.keys[r.Header.Get("X-Exosite-CIK")]
+	if client == nil {
+		s.mu.Unlock()
+		provisionError(w, http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method == "POST" {
+		for alias := range writes {
+			dataport := s.resources[client.aliases[alias]]
+			if dataport == nil {
+				s.mu.Unlock()
+				provisionError(w, http.StatusBadRequest)
+				return
+			}
+			if err := s.write(dataport, s.now(), writes.Get(alias)); err != nil {
+				s.mu.Unlock()
+				provisionError(w, http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	if r.Method == "GET" && r.Header.Get("Request-Timeout") != "" {
+		s.longPoll(w, r, client, reads)
+		return
+	}
+
+	s.mu.Lock()
+	values := url.Values{}
+	for alias := range reads {
+		dataport := s.resources[client.aliases[alias]]
+		if dataport != nil && len(dataport.points) > 0 {
+			values.Set(alias, fmt.Sprint(dataport.points[len(dataport.points)-1].value))
+		}
+	}
+	s.mu.Unlock()
+
+	if len(values) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	w.Write([]byte(values.Encode()))
+}
+
+// longPoll waits for a value of the single alias asked for that is newer
+// than If-Modified-Since, answering 304 when Request-Timeout passes first
+func (s *Server) longPoll(w http.ResponseWriter, r *http.Request, client *resource, reads url.Values) {
+	if len(reads) != 1 {
+		provisionError(w, http.StatusBadRequest)
+		return
+	}
+	var alias string
+	for alias = range reads {
+	}
+
+	since := s.now()
+	if header := r.Header.Get("If-Modified-Since"); header != "" {
+		if seconds, err := strconv.ParseInt(header, 10, 64); err == nil {
+			since = seconds
+		} else if t, err := http.ParseTime(header); err == nil {
+			since = t.Unix()
+		}
+	}
+	timeout, _ := strconv.Atoi(r.Header.Get("Request-Timeout"))
+	timer := time.NewTimer(time.Duration(timeout) * time.Millisecond)
+	defer timer.Stop()
+
+	for {
+		s.mu.Lock()
+		dataport := s.resources[client.aliases[alias]]
+		if dataport == nil {
+			s.mu.Unlock()
+			provisionError(w, http.StatusBadRequest)
+			return
+		}
+		if n := len(dataport.points); n > 0 && dataport.points[n-1].ts > since {
+			p := dataport.points[n-1]
+			s.mu.Unlock()
+			w.Header().Set("Last-Modified", time.Unix(p.ts, 0).UTC().Format(http.TimeFormat))
+			w.Header().Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+			w.Write([]byte(url.Values{alias: {fmt.Sprint(p.value)}}.Encode()))
+			return
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			w.WriteHeader(http.StatusNotModified)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *Server) serveTimestamp(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(strconv.FormatInt(s.now(), 10)))
+}
@@ -0,0 +1,688 @@
+package onepfake
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type vendor struct {
+	name   string
+	models map[string]*model
+	groups map[string]*group
+	shares map[string]*vendorShare
+}
+
+type group struct {
+	name    string
+	meta    string
+	members []string
+}
+
+type vendorShare struct {
+	code string
+	meta string
+}
+
+type model struct {
+	name     string
+	cloneRID string
+	code     string
+	options  []string
+
+	serialNumbers map[string]*serialNumber
+	snOrder       []string
+
+	content      map[string]*content
+	contentOrder []string
+}
+
+type serialNumber struct {
+	sn     string
+	status string // "unused", "notactivated", "activated" or "disabled"
+	rid    string
+	extra  string
+}
+
+type content struct {
+	id        string
+	meta      string
+	mime      string
+	protected bool
+	data      []byte
+	updated   int64
+}
+
+func (s *Server) vendorNamed(name string) *vendor {
+	v := s.vendors[name]
+	if v == nil {
+		v = &vendor{
+			name:   name,
+			models: map[string]*model{},
+			groups: map[string]*group{},
+			shares: map[string]*vendorShare{},
+		}
+		s.vendors[name] = v
+	}
+	return v
+}
+
+// provisionError answers with status code, using the status line as body
+// the way the platform does
+func provisionError(w http.ResponseWriter, code int) {
+	w.WriteHeader(code)
+	fmt.Fprintf(w, "HTTP/1.1 %d %s\r\n", code, http.StatusText(code))
+}
+
+func writeText(w http.ResponseWriter, lines []string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(strings.Join(lines, "\r\n")))
+}
+
+// requestKey returns the vendor token or CIK a provisioning request is
+// made with
+func requestKey(r *http.Request) (key string, cik bool) {
+	if key := r.Header.Get("X-Exosite-CIK"); key != "" {
+		return key, true
+	}
+	return r.Header.Get("X-Exosite-Token"), false
+}
+
+func (s *Server) serveProvision(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/provision")
+	switch {
+	case path == "/activate":
+		s.provisionActivate(w, formParams(r, body))
+		return
+	case path == "/download":
+		s.provisionDownload(w, r, formParams(r, body))
+		return
+	case path == "/register":
+		s.provisionRegister(w, r, formParams(r, body))
+		return
+	}
+
+	// a CIK is only accepted in the CIK header and a vendor token only in
+	// the token header
+	key, cik := requestKey(r)
+	v := s.vendors[s.vendorKeys[key]]
+	if v == nil || cik != (s.keys[key] != nil) {
+		provisionError(w, http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(path, "/manage/model/"):
+		segments := strings.Split(strings.TrimPrefix(path, "/manage/model/"), "/")
+		params := formParams(r, body)
+		switch {
+		case segments[0] == "":
+			s.provisionModels(w, r, v, params)
+		case len(segments) == 1:
+			s.provisionModel(w, r, v, segments[0], params)
+		case segments[1] == "":
+			s.provisionSerialNumbers(w, r, v, segments[0], params)
+		default:
+			s.provisionSerialNumber(w, r, v, segments[0], segments[1], params)
+		}
+	case strings.HasPrefix(path, "/manage/group/"):
+		s.provisionGroups(w, r, v, strings.TrimPrefix(path, "/manage/group/"), formParams(r, body))
+	case strings.HasPrefix(path, "/manage/share/"):
+		s.provisionShares(w, r, v, strings.TrimPrefix(path, "/manage/share/"), formParams(r, body))
+	case strings.HasPrefix(path, "/manage/content/"):
+		segments := strings.Split(strings.TrimPrefix(path, "/manage/content/"), "/")
+		m := v.models[segments[0]]
+		if m == nil {
+			provisionError(w, http.StatusNotFound)
+			return
+		}
+		if len(segments) == 1 || segments[1] == "" {
+			s.provisionContents(w, r, m, formParams(r, body))
+		} else {
+			s.provisionContent(w, r, m, segments[1], body, formParams(r, body))
+		}
+	default:
+		provisionError(w, http.StatusNotFound)
+	}
+}
+
+// formParams merges the query string with the body when it is form
+// encoded. Like a real server, bodies of any other content type are not
+// read as parameters.
+func formParams(r *http.Request, body []byte) url.Values {
+	params := url.Values{}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/x-www-form-urlencoded" {
+		if form, err := url.ParseQuery(string(body)); err == nil {
+			params = form
+		}
+	}
+	for k, v := range r.URL.Query() {
+		params[k] = append(params[k], v...)
+	}
+	return params
+}
+
+func (s *Server) provisionRegister(w http.ResponseWriter, r *http.Request, params url.Values) {
+	key, cik := requestKey(r)
+	if cik && s.keys[key] == nil || !cik && s.vendorKeys[key] == "" {
+		provisionError(w, http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		var names []string
+		if name := s.vendorKeys[key]; name != "" {
+			names = append(names, name)
+		}
+		writeText(w, names)
+	case "POST":
+		name := params.Get("vendor")
+		if name == "" {
+			provisionError(w, http.StatusBadRequest)
+			return
+		}
+		if params.Get("delete") == "true" {
+			if s.vendorKeys[key] != name {
+				provisionError(w, http.StatusNotFound)
+				return
+			}
+			delete(s.vendorKeys, key)
+			delete(s.vendors, name)
+			return
+		}
+		if _, taken := s.vendors[name]; taken {
+			provisionError(w, http.StatusConflict)
+			return
+		}
+		s.vendorNamed(name)
+		s.vendorKeys[key] = name
+	default:
+		provisionError(w, http.StatusMethodNotAllowed)
+	}
+}
+
+func (m *model) info() string {
+	values := url.Values{}
+	if m.code != "" {
+		values.Set("code", m.code)
+	}
+	values.Set("rid", m.cloneRID)
+	for _, option := range m.options {
+		values.Add("options[]", option)
+	}
+	return values.Encode()
+}
+
+func (m *model) has(option string) bool {
+	for _, o := range m.options {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// setCloneSource points m at the client named by the rid or code parameter
+func (s *Server) setCloneSource(m *model, params url.Values) int {
+	if code := params.Get("code"); code != "" {
+		sh := s.shares[code]
+		if sh == nil {
+			return http.StatusPreconditionFailed
+		}
+		m.code = code
+		m.cloneRID = sh.resource.rid
+	} else {
+		if s.resources[params.Get("rid")] == nil {
+			return http.StatusPreconditionFailed
+		}
+		m.code = ""
+		m.cloneRID = params.Get("rid")
+	}
+	m.options = append([]string(nil), params["options[]"]...)
+	return http.StatusOK
+}
+
+func (s *Server) provisionModels(w http.ResponseWriter, r *http.Request, v *vendor, params url.Values) {
+	switch r.Method {
+	case "GET":
+		var names []string
+		for name := range v.models {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		writeText(w, names)
+	case "POST":
+		name := params.Get("model")
+		if name == "" {
+			provisionError(w, http.StatusBadRequest)
+			return
+		}
+		if v.models[name] != nil {
+			provisionError(w, http.StatusConflict)
+			return
+		}
+		m := &model{
+			name:          name,
+			serialNumbers: map[string]*serialNumber{},
+			content:       map[string]*content{},
+		}
+		if code := s.setCloneSource(m, params); code != http.StatusOK {
+			provisionError(w, code)
+			return
+		}
+		v.models[name] = m
+		w.WriteHeader(http.StatusCreated)
+	default:
+		provisionError(w, http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) provisionModel(w http.ResponseWriter, r *http.Request, v *vendor, name string, params url.Values) {
+	m := v.models[name]
+	if m == nil {
+		provisionError(w, http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case "GET":
+		writeText(w, []string{m.info()})
+	case "PUT":
+		if code := s.setCloneSource(m, params); code != http.StatusOK {
+			provisionError(w, code)
+		}
+	case "DELETE":
+		delete(v.models, name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		provisionError(w, http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) provisionSerialNumbers(w http.ResponseWriter, r *http.Request, v *vendor, name string, params url.Values) {
+	m := v.models[name]
+	if m == nil {
+		provisionError(w, http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case "GET":
+		offset, _ := strconv.Atoi(params.Get("offset"))
+		limit, err := strconv.Atoi(params.Get("limit"))
+		if err != nil {
+			limit = 1000
+		}
+		status := params.Get("status")
+		var lines []string
+		for _, sn := range m.snOrder {
+			entry := m.serialNumbers[sn]
+			if status != "" && entry.status != status {
+				continue
+			}
+			if offset > 0 {
+				offset--
+				continue
+			}
+			if len(lines) >= limit {
+				break
+			}
+			lines = append(lines, entry.sn+","+entry.rid+","+entry.extra)
+		}
+		writeText(w, lines)
+	case "POST":
+		sns := append(params["sn[]"], params["sn"]...)
+		switch {
+		case params.Get("add") == "true":
+			if len(sns) == 1 && m.serialNumbers[sns[0]] != nil {
+				provisionError(w, http.StatusConflict)
+				return
+			}
+			for _, sn := range sns {
+				if m.serialNumbers[sn] == nil {
+					m.serialNumbers[sn] = &serialNumber{sn: sn, status: "unused"}
+					m.snOrder = append(m.snOrder, sn)
+				}
+			}
+		case params.Get("remove") == "true":
+			for _, sn := range sns {
+				m.removeSerialNumber(sn)
+			}
+		default:
+			provisionError(w, http.StatusBadRequest)
+		}
+	default:
+		provisionError(w, http.StatusMethodNotAllowed)
+	}
+}
+
+func (m *model) removeSerialNumber(sn string) {
+	if m.serialNumbers[sn] == nil {
+		return
+	}
+	delete(m.serialNumbers, sn)
+	for i, s := range m.snOrder {
+		if s == sn {
+			m.snOrder = append(m.snOrder[:i:i], m.snOrder[i+1:]...)
+			break
+		}
+	}
+}
+
+func (s *Server) provisionSerialNumber(w http.ResponseWriter, r *http.Request, v *vendor, name, sn string, params url.Values) {
+	m := v.models[name]
+	if m == nil || m.serialNumbers[sn] == nil {
+		provisionError(w, http.StatusNotFound)
+		return
+	}
+	entry := m.serialNumbers[sn]
+
+	switch r.Method {
+	case "GET":
+		writeText(w, []string{entry.status + "," + entry.rid + "," + entry.extra})
+	case "DELETE":
+		m.removeSerialNumber(sn)
+		w.WriteHeader(http.StatusNoContent)
+	case "POST":
+		switch {
+		case params.Get("disable") == "true":
+			entry.status = "disabled"
+		case params.Get("enable") == "true" && params.Get("owner") != "":
+			if entry.rid != "" {
+				provisionError(w, http.StatusConflict)
+				return
+			}
+			owner := s.resources[params.Get("owner")]
+			source := s.resources[m.cloneRID]
+			if owner == nil || owner.typ != "client" || source == nil {
+				provisionError(w, http.StatusPreconditionFailed)
+				return
+			}
+			clone := s.clone(owner, source, !m.has("noaliases"), !m.has("nocomments"), !m.has("nohistorical"))
+			entry.rid = clone.rid
+			entry.status = "notactivated"
+		case params.Get("enable") == "true" && params.Get("oldsn") != "":
+			old := m.serialNumbers[params.Get("oldsn")]
+			if old == nil || old.rid == "" {
+				provisionError(w, http.StatusPreconditionFailed)
+				return
+			}
+			entry.rid, old.rid = old.rid, ""
+			entry.status, old.status = "notactivated", "unused"
+		case params.Get("enable") == "true":
+			if entry.rid == "" {
+				provisionError(w, http.StatusPreconditionFailed)
+				return
+			}
+			entry.status = "notactivated"
+		default:
+			provisionError(w, http.StatusBadRequest)
+		}
+	default:
+		provisionError(w, http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) provisionActivate(w http.ResponseWriter, params url.Values) {
+	v := s.vendors[params.Get("vendor")]
+	if v == nil || v.models[params.Get("model")] == nil {
+		provisionError(w, http.StatusNotFound)
+		return
+	}
+	entry := v.models[params.Get("model")].serialNumbers[params.Get("sn")]
+	if entry == nil || entry.rid == "" || entry.status == "disabled" {
+		provisionError(w, http.StatusNotFound)
+		return
+	}
+	if entry.status == "activated" {
+		provisionError(w, http.StatusConflict)
+		return
+	}
+	client := s.resources[entry.rid]
+	if client == nil {
+		provisionError(w, http.StatusNotFound)
+		return
+	}
+
+	// activation hands out a fresh CIK
+	delete(s.keys, client.key)
+	client.key = newID()
+	s.keys[client.key] = client
+	entry.status = "activated"
+	writeText(w, []string{client.key})
+}
+
+func (s *Server) provisionContents(w http.ResponseWriter, r *http.Request, m *model, params url.Values) {
+	switch r.Method {
+	case "GET":
+		writeText(w, m.contentOrder)
+	case "POST":
+		id := params.Get("id")
+		if id == "" {
+			provisionError(w, http.StatusBadRequest)
+			return
+		}
+		if m.content[id] != nil {
+			provisionError(w, http.StatusConflict)
+			return
+		}
+		m.content[id] = &content{
+			id:        id,
+			meta:      params.Get("meta"),
+			protected: params.Get("protected") == "true",
+			updated:   s.now(),
+		}
+		m.contentOrder = append(m.contentOrder, id)
+		w.WriteHeader(http.StatusCreated)
+	default:
+		provisionError(w, http.StatusMethodNotAllowed)
+	}
+}
+
+func (c *content) info() string {
+	return fmt.Sprintf("%s,%d,%d,%s,%t", c.mime, len(c.data), c.updated, c.meta, c.protected)
+}
+
+func (s *Server) provisionContent(w http.ResponseWriter, r *http.Request, m *model, id string, body []byte, params url.Values) {
+	c := m.content[id]
+	if c == nil {
+		provisionError(w, http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case "GET":
+		writeText(w, []string{c.info()})
+	case "POST":
+		c.mime = r.Header.Get("Content-Type")
+		c.data = body
+		c.updated = s.now()
+		w.WriteHeader(http.StatusNoContent)
+	case "PUT":
+		c.meta = params.Get("meta")
+		c.protected = params.Get("protected") == "true"
+		c.updated = s.now()
+		w.WriteHeader(http.StatusNoContent)
+	case "DELETE":
+		delete(m.content, id)
+		for i, cid := range m.contentOrder {
+			if cid == id {
+				m.contentOrder = append(m.contentOrder[:i:i], m.contentOrder[i+1:]...)
+				break
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		provisionError(w, http.StatusMethodNotAllowed)
+	}
+}
+
+// provisionDownload serves content to devices authenticated with their
+// CIK, which must be sent as X-Exosite-CIK. Range requests are honoured.
+func (s *Server) provisionDownload(w http.ResponseWriter, r *http.Request, params url.Values) {
+	cik := r.Header.Get("X-Exosite-CIK")
+	if s.keys[cik] == nil {
+		provisionError(w, http.StatusUnauthorized)
+		return
+	}
+	v := s.vendors[params.Get("vendor")]
+	if v == nil || v.models[params.Get("model")] == nil {
+		provisionError(w, http.StatusNotFound)
+		return
+	}
+	m := v.models[params.Get("model")]
+
+	if params.Get("info") == "true" && params.Get("id") == "" {
+		writeText(w, m.contentOrder)
+		return
+	}
+	c := m.content[params.Get("id")]
+	if c == nil {
+		provisionError(w, http.StatusNotFound)
+		return
+	}
+	if c.protected && !m.activated(s.keys[cik].rid) {
+		provisionError(w, http.StatusForbidden)
+		return
+	}
+	if params.Get("info") == "true" {
+		writeText(w, []string{c.info()})
+		return
+	}
+	serveContent(w, r, c)
+}
+
+// activated reports whether rid is the client of an activated serial number
+func (m *model) activated(rid string) bool {
+	for _, entry := range m.serialNumbers {
+		if entry.rid == rid && entry.status == "activated" {
+			return true
+		}
+	}
+	return false
+}
+
+func serveContent(w http.ResponseWriter, r *http.Request, c *content) {
+	if c.mime != "" {
+		w.Header().Set("Content-Type", c.mime)
+	}
+	http.ServeContent(w, r, c.id, time.Unix(c.updated, 0), bytes.NewReader(c.data))
+}
+
+// provisionGroups serves the group listing when name is empty and a single
+// group otherwise
+func (s *Server) provisionGroups(w http.ResponseWriter, r *http.Request, v *vendor, name string, params url.Values) {
+	if name == "" {
+		switch r.Method {
+		case "GET":
+			var names []string
+			for name := range v.groups {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			writeText(w, names)
+		case "POST":
+			name := params.Get("name")
+			if name == "" {
+				provisionError(w, http.StatusBadRequest)
+				return
+			}
+			if v.groups[name] != nil {
+				provisionError(w, http.StatusConflict)
+				return
+			}
+			v.groups[name] = &group{name: name, meta: params.Get("meta"), members: params["members[]"]}
+			w.WriteHeader(http.StatusCreated)
+		default:
+			provisionError(w, http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	g := v.groups[name]
+	if g == nil {
+		provisionError(w, http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case "GET":
+		values := url.Values{"meta": {g.meta}}
+		for _, member := range g.members {
+			values.Add("members[]", member)
+		}
+		writeText(w, []string{values.Encode()})
+	case "PUT":
+		g.meta = params.Get("meta")
+		g.members = params["members[]"]
+		w.WriteHeader(http.StatusNoContent)
+	case "DELETE":
+		delete(v.groups, name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		provisionError(w, http.StatusMethodNotAllowed)
+	}
+}
+
+// provisionShares serves the share code listing when code is empty and a
+// single share code otherwise. Only share codes handed out by the
+// platform can be registered.
+func (s *Server) provisionShares(w http.ResponseWriter, r *http.Request, v *vendor, code string, params url.Values) {
+	if code == "" {
+		switch r.Method {
+		case "GET":
+			var codes []string
+			for code := range v.shares {
+				codes = append(codes, code)
+			}
+			sort.Strings(codes)
+			writeText(w, codes)
+		case "POST":
+			code := params.Get("code")
+			if s.shares[code] == nil {
+				provisionError(w, http.StatusPreconditionFailed)
+				return
+			}
+			if v.shares[code] != nil {
+				provisionError(w, http.StatusConflict)
+				return
+			}
+			v.shares[code] = &vendorShare{code: code, meta: params.Get("meta")}
+			w.WriteHeader(http.StatusCreated)
+		default:
+			provisionError(w, http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	vs := v.shares[code]
+	if vs == nil {
+		provisionError(w, http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case "GET":
+		values := url.Values{"meta": {vs.meta}}
+		if sh := s.shares[code]; sh != nil {
+			values.Set("rid", sh.resource.rid)
+		}
+		writeText(w, []string{values.Encode()})
+	case "PUT":
+		vs.meta = params.Get("meta")
+		w.WriteHeader(http.StatusNoContent)
+	case "DELETE":
+		delete(v.shares, code)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		provisionError(w, http.StatusMethodNotAllowed)
+	}
+}
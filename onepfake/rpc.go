@@ -0,0 +1,672 @@
+package onepfake
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+type rpcCall struct {
+	Id        interface{}   `json:"id"`
+	Procedure string        `json:"procedure"`
+	Arguments []interface{} `json:"arguments"`
+}
+
+// callError is the failure of a single call, reported either with a status
+// or, when code is set, with an error object
+type callError struct {
+	status  string
+	code    int
+	message string
+}
+
+var (
+	errInvalid    = &callError{status: "invalid"}
+	errBadArg     = &callError{status: "badarg"}
+	errRestricted = &callError{status: "restricted"}
+	errExpire     = &callError{status: "expire"}
+)
+
+func (s *Server) serveRPC(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Auth  interface{} `json:"auth"`
+		Calls []rpcCall   `json:"calls"`
+	}
+	d := json.NewDecoder(r.Body)
+	d.UseNumber()
+	if err := d.Decode(&request); err != nil {
+		writeJSON(w, map[string]interface{}{
+			"error": map[string]interface{}{"code": 400, "message": "Bad Request", "context": "json"},
+		})
+		return
+	}
+
+	s.mu.Lock()
+	client := s.authenticate(request.Auth)
+	s.mu.Unlock()
+	if client == nil {
+		writeJSON(w, map[string]interface{}{
+			"error": map[string]interface{}{"code": 401, "message": "Unauthorized", "context": "auth"},
+		})
+		return
+	}
+
+	results := make([]interface{}, 0, len(request.Calls))
+	for _, call := range request.Calls {
+		var body interface{}
+		var err *callError
+		if call.Procedure == "wait" {
+			body, err = s.wait(r, client, call.Arguments)
+		} else {
+			s.mu.Lock()
+			body, err = s.call(client, call.Procedure, call.Arguments)
+			s.mu.Unlock()
+		}
+
+		result := map[string]interface{}{"id": call.Id}
+		switch {
+		case err == nil:
+			result["status"] = "ok"
+			if body != nil {
+				result["result"] = body
+			}
+		case err.code != 0:
+			result["error"] = map[string]interface{}{"code": err.code, "message": err.message, "context": call.Procedure}
+		default:
+			result["status"] = err.status
+		}
+		results = append(results, result)
+	}
+	writeJSON(w, results)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(v)
+}
+
+// authenticate returns the client a request acts as
+func (s *Server) authenticate(auth interface{}) *resource {
+	m, ok := auth.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	cik, _ := m["cik"].(string)
+	client := s.keys[cik]
+	if client == nil || client.status != "activated" {
+		return nil
+	}
+	for _, key := range []string{"client_id", "resource_id"} {
+		if rid, ok := m[key].(string); ok {
+			descendant := s.resources[rid]
+			if descendant == nil || descendant.typ != "client" || !owns(client, descendant) {
+				return nil
+			}
+			client = descendant
+		}
+	}
+	return client
+}
+
+// resolve finds the resource an RID argument names. The argument is either
+// an RID or an {"alias": name} object looked up in client.
+func (s *Server) resolve(client *resource, arg interface{}) (*resource, *callError) {
+	var rid string
+	switch arg := arg.(type) {
+	case string:
+		rid = arg
+	case map[string]interface{}:
+		alias, ok := arg["alias"].(string)
+		if !ok {
+			return nil, errBadArg
+		}
+		if alias == "" {
+			return client, nil
+		}
+		if rid, ok = client.aliases[alias]; !ok {
+			return nil, errInvalid
+		}
+	default:
+		return nil, errBadArg
+	}
+
+	r := s.resources[rid]
+	if r == nil {
+		return nil, errInvalid
+	}
+	if !owns(client, r) {
+		return nil, errRestricted
+	}
+	return r, nil
+}
+
+func argument(arguments []interface{}, i int) interface{} {
+	if i < len(arguments) {
+		return arguments[i]
+	}
+	return nil
+}
+
+func options(arguments []interface{}, i int) map[string]interface{} {
+	m, _ := argument(arguments, i).(map[string]interface{})
+	if m == nil {
+		m = map[string]interface{}{}
+	}
+	return m
+}
+
+func number(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// call carries out a single procedure on behalf of client
+func (s *Server) call(client *resource, procedure string, arguments []interface{}) (interface{}, *callError) {
+	switch procedure {
+	case "activate", "deactivate":
+		return s.activate(client, procedure == "activate", arguments)
+	case "create":
+		return s.create(client, arguments)
+	case "comment":
+		r, err := s.resolve(client, argument(arguments, 0))
+		if err != nil {
+			return nil, err
+		}
+		visibility, _ := argument(arguments, 1).(string)
+		text, _ := argument(arguments, 2).(string)
+		if visibility != "public" && visibility != "private" {
+			return nil, errBadArg
+		}
+		r.comments = append(r.comments, []string{visibility, text})
+		return nil, nil
+	case "tag":
+		r, err := s.resolve(client, argument(arguments, 0))
+		if err != nil {
+			return nil, err
+		}
+		action, _ := argument(arguments, 1).(string)
+		tag, _ := argument(arguments, 2).(string)
+		if tag == "" {
+			return nil, errBadArg
+		}
+		var tags []string
+		for _, t := range r.tags {
+			if t != tag {
+				tags = append(tags, t)
+			}
+		}
+		switch action {
+		case "add":
+			tags = append(tags, tag)
+		case "remove":
+		default:
+			return nil, errBadArg
+		}
+		r.tags = tags
+		return nil, nil
+	case "drop":
+		r, err := s.resolve(client, argument(arguments, 0))
+		if err != nil {
+			return nil, err
+		}
+		if r == client {
+			return nil, errRestricted
+		}
+		s.drop(r)
+		return nil, nil
+	case "flush":
+		r, err := s.resolve(client, argument(arguments, 0))
+		if err != nil {
+			return nil, err
+		}
+		r.points = nil
+		return nil, nil
+	case "info":
+		r, err := s.resolve(client, argument(arguments, 0))
+		if err != nil {
+			return nil, err
+		}
+		return s.info(r, options(arguments, 1)), nil
+	case "listing":
+		return s.listing(client, arguments)
+	case "lookup":
+		return s.lookup(client, arguments)
+	case "map":
+		return s.mapAlias(client, arguments)
+	case "unmap":
+		alias, _ := argument(arguments, 1).(string)
+		if _, ok := client.aliases[alias]; !ok {
+			return nil, errInvalid
+		}
+		delete(client.aliases, alias)
+		return nil, nil
+	case "read":
+		r, err := s.resolve(client, argument(arguments, 0))
+		if err != nil {
+			return nil, err
+		}
+		return s.read(r, options(arguments, 1)), nil
+	case "record", "recordbatch":
+		return s.recordEntries(client, arguments)
+	case "write":
+		r, err := s.resolve(client, argument(arguments, 0))
+		if err != nil {
+			return nil, err
+		}
+		return nil, s.write(r, s.now(), argument(arguments, 1))
+	case "writegroup":
+		return s.writegroup(client, arguments)
+	case "update":
+		r, err := s.resolve(client, argument(arguments, 0))
+		if err != nil {
+			return nil, err
+		}
+		desc, ok := argument(arguments, 1).(map[string]interface{})
+		if !ok {
+			return nil, errBadArg
+		}
+		for k, v := range desc {
+			r.desc[k] = v
+		}
+		r.modified = s.now()
+		return nil, nil
+	case "share":
+		r, err := s.resolve(client, argument(arguments, 0))
+		if err != nil {
+			return nil, err
+		}
+		sh := &share{code: newID(), resource: r, meta: options(arguments, 1)["meta"]}
+		s.shares[sh.code] = sh
+		return sh.code, nil
+	case "revoke":
+		code, _ := argument(arguments, 1).(string)
+		if argument(arguments, 0) != "share" || s.shares[code] == nil {
+			return nil, errInvalid
+		}
+		delete(s.shares, code)
+		return nil, nil
+	case "usage":
+		if _, err := s.resolve(client, argument(arguments, 0)); err != nil {
+			return nil, err
+		}
+		return 0, nil
+	}
+	return nil, &callError{code: 501, message: "Not Implemented"}
+}
+
+func (s *Server) activate(client *resource, activate bool, arguments []interface{}) (interface{}, *callError) {
+	code, _ := argument(arguments, 1).(string)
+	switch argument(arguments, 0) {
+	case "client":
+		r := s.keys[code]
+		if r == nil || !owns(client, r) || r == client {
+			return nil, errInvalid
+		}
+		if activate {
+			r.status = "activated"
+		} else {
+			r.status = "deactivated"
+		}
+		return nil, nil
+	case "share":
+		if s.shares[code] == nil {
+			return nil, errInvalid
+		}
+		return nil, nil
+	}
+	return nil, errBadArg
+}
+
+func (s *Server) create(client *resource, arguments []interface{}) (interface{}, *callError) {
+	typ, _ := argument(arguments, 0).(string)
+	desc, ok := argument(arguments, 1).(map[string]interface{})
+	if !ok {
+		return nil, errBadArg
+	}
+	switch typ {
+	case "dataport", "datarule":
+		switch desc["format"] {
+		case "float", "integer", "string":
+		default:
+			return nil, errBadArg
+		}
+	case "client", "dispatch":
+	default:
+		return nil, errBadArg
+	}
+	copied := map[string]interface{}{}
+	for k, v := range desc {
+		copied[k] = v
+	}
+	return s.newResource(client, typ, copied).rid, nil
+}
+
+func (s *Server) info(r *resource, opts map[string]interface{}) map[string]interface{} {
+	all := len(opts) == 0
+	want := func(key string) bool {
+		return all || opts[key] == true
+	}
+
+	info := map[string]interface{}{}
+	if want("aliases") {
+		if r.typ == "client" {
+			aliases := map[string][]string{}
+			for alias, rid := range r.aliases {
+				aliases[rid] = append(aliases[rid], alias)
+			}
+			for rid := range aliases {
+				sort.Strings(aliases[rid])
+			}
+			info["aliases"] = aliases
+		} else {
+			info["aliases"] = []interface{}{}
+		}
+	}
+	if want("basic") {
+		info["basic"] = map[string]interface{}{
+			"created":     r.created,
+			"modified":    r.modified,
+			"status":      r.status,
+			"subscribers": 0,
+			"type":        r.typ,
+		}
+	}
+	if want("comments") {
+		comments := r.comments
+		if comments == nil {
+			comments = [][]string{}
+		}
+		info["comments"] = comments
+	}
+	if want("counts") {
+		counts := map[string]int{"client": 0, "dataport": 0, "datarule": 0, "dispatch": 0}
+		for _, child := range r.children {
+			counts[child.typ]++
+		}
+		info["counts"] = counts
+	}
+	if want("description") {
+		info["description"] = r.desc
+	}
+	if want("key") && r.key != "" {
+		info["key"] = r.key
+	}
+	if want("shares") {
+		info["shares"] = []interface{}{}
+	}
+	if want("storage") && r.typ != "client" {
+		storage := map[string]interface{}{"count": len(r.points), "first": 0, "last": 0, "size": 0}
+		if len(r.points) > 0 {
+			storage["first"] = r.points[0].ts
+			storage["last"] = r.points[len(r.points)-1].ts
+			size := 0
+			for _, p := range r.points {
+				size += len(fmt.Sprint(p.value))
+			}
+			storage["size"] = size
+		}
+		info["storage"] = storage
+	}
+	if want("subscribers") {
+		info["subscribers"] = []interface{}{}
+	}
+	if want("tags") {
+		tags := r.tags
+		if tags == nil {
+			tags = []string{}
+		}
+		info["tags"] = tags
+	}
+	if want("usage") {
+		info["usage"] = map[string]int{}
+	}
+	return info
+}
+
+func (s *Server) listing(client *resource, arguments []interface{}) (interface{}, *callError) {
+	types, ok := argument(arguments, 0).([]interface{})
+	if !ok {
+		return nil, errBadArg
+	}
+	byType := map[string][]string{}
+	var order []string
+	for _, t := range types {
+		typ, _ := t.(string)
+		byType[typ] = []string{}
+		order = append(order, typ)
+	}
+	for _, child := range client.children {
+		if rids, ok := byType[child.typ]; ok {
+			byType[child.typ] = append(rids, child.rid)
+		}
+	}
+
+	if len(arguments) > 1 {
+		return byType, nil
+	}
+	positional := make([][]string, 0, len(order))
+	for _, typ := range order {
+		positional = append(positional, byType[typ])
+	}
+	return positional, nil
+}
+
+func (s *Server) lookup(client *resource, arguments []interface{}) (interface{}, *callError) {
+	value, _ := argument(arguments, 1).(string)
+	switch argument(arguments, 0) {
+	case "alias":
+		if value == "" {
+			return client.rid, nil
+		}
+		rid, ok := client.aliases[value]
+		if !ok {
+			return nil, errInvalid
+		}
+		return rid, nil
+	case "owner":
+		r, err := s.resolve(client, value)
+		if err != nil {
+			return nil, err
+		}
+		if r.parent == nil {
+			return nil, errRestricted
+		}
+		return r.parent.rid, nil
+	case "shared":
+		sh := s.shares[value]
+		if sh == nil {
+			return nil, errInvalid
+		}
+		return sh.resource.rid, nil
+	}
+	return nil, errBadArg
+}
+
+func (s *Server) mapAlias(client *resource, arguments []interface{}) (interface{}, *callError) {
+	if argument(arguments, 0) != "alias" {
+		return nil, errBadArg
+	}
+	r, err := s.resolve(client, argument(arguments, 1))
+	if err != nil {
+		return nil, err
+	}
+	alias, ok := argument(arguments, 2).(string)
+	if !ok || alias == "" {
+		return nil, errBadArg
+	}
+	if _, taken := client.aliases[alias]; taken {
+		return nil, errInvalid
+	}
+	client.aliases[alias] = r.rid
+	return nil, nil
+}
+
+func (s *Server) read(r *resource, opts map[string]interface{}) interface{} {
+	start, end := math.Inf(-1), math.Inf(1)
+	if v, ok := number(opts["starttime"]); ok {
+		start = v
+	}
+	if v, ok := number(opts["endtime"]); ok {
+		end = v
+	}
+	limit := 1
+	if v, ok := number(opts["limit"]); ok {
+		limit = int(v)
+	}
+	ascending := opts["sort"] == "asc"
+
+	points := [][]interface{}{}
+	for i := range r.points {
+		p := r.points[len(r.points)-1-i]
+		if ascending {
+			p = r.points[i]
+		}
+		if float64(p.ts) < start || float64(p.ts) > end {
+			continue
+		}
+		if len(points) >= limit {
+			break
+		}
+		points = append(points, []interface{}{p.ts, p.value})
+	}
+	return points
+}
+
+// write records value at ts after converting it to r's format
+func (s *Server) write(r *resource, ts int64, value interface{}) *callError {
+	if r.typ == "client" {
+		return errBadArg
+	}
+	switch r.desc["format"] {
+	case "integer":
+		f, ok := number(value)
+		if !ok {
+			return errBadArg
+		}
+		value = int64(f)
+	case "float":
+		f, ok := number(value)
+		if !ok {
+			return errBadArg
+		}
+		value = f
+	default:
+		if n, ok := value.(json.Number); ok {
+			value = n.String()
+		}
+		if _, ok := value.(string); !ok {
+			value = fmt.Sprint(value)
+		}
+	}
+	s.record(r, ts, value)
+	return nil
+}
+
+func (s *Server) recordEntries(client *resource, arguments []interface{}) (interface{}, *callError) {
+	r, err := s.resolve(client, argument(arguments, 0))
+	if err != nil {
+		return nil, err
+	}
+	entries, ok := argument(arguments, 1).([]interface{})
+	if !ok {
+		return nil, errBadArg
+	}
+	for _, entry := range entries {
+		pair, ok := entry.([]interface{})
+		if !ok || len(pair) != 2 {
+			return nil, errBadArg
+		}
+		ts, ok := number(pair[0])
+		if !ok {
+			return nil, errBadArg
+		}
+		if ts < 0 {
+			ts += float64(s.now())
+		}
+		if err := s.write(r, int64(ts), pair[1]); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+func (s *Server) writegroup(client *resource, arguments []interface{}) (interface{}, *callError) {
+	entries, ok := argument(arguments, 0).([]interface{})
+	if !ok {
+		return nil, errBadArg
+	}
+	now := s.now()
+	for _, entry := range entries {
+		pair, ok := entry.([]interface{})
+		if !ok || len(pair) != 2 {
+			return nil, errBadArg
+		}
+		r, err := s.resolve(client, pair[0])
+		if err != nil {
+			return nil, err
+		}
+		if err := s.write(r, now, pair[1]); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// wait blocks until a point newer than the "since" option is recorded,
+// the "timeout" option in milliseconds passes or the request goes away
+func (s *Server) wait(req *http.Request, client *resource, arguments []interface{}) (interface{}, *callError) {
+	s.mu.Lock()
+	r, err := s.resolve(client, argument(arguments, 0))
+	opts := options(arguments, 1)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	since := float64(s.now())
+	if v, ok := number(opts["since"]); ok {
+		since = v
+	}
+	timeout := 30 * time.Second
+	if v, ok := number(opts["timeout"]); ok {
+		timeout = time.Duration(v) * time.Millisecond
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		s.mu.Lock()
+		if n := len(r.points); n > 0 && float64(r.points[n-1].ts) > since {
+			p := r.points[n-1]
+			s.mu.Unlock()
+			return []interface{}{p.ts, p.value}, nil
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			return nil, errExpire
+		case <-req.Context().Done():
+			return nil, errExpire
+		}
+	}
+}
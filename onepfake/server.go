@@ -0,0 +1,246 @@
+// Package onepfake is an in-memory stand-in for the One Platform. It
+// answers the JSON RPC, the HTTP Data Interface and the provisioning API
+// well enough to test code built on goonep without network access.
+//
+//	fake := onepfake.New()
+//	server := httptest.NewServer(fake)
+//	defer server.Close()
+//
+//	cik := fake.NewClient()
+//	client := &goonep.Client{Scheme: "http", Host: strings.TrimPrefix(server.URL, "http://")}
+//	resp, err := client.Lookup(cik, "alias", "")
+//
+// Datarule scripts are stored but never run, and dispatches never send
+// anything.
+package onepfake
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is the fake platform. It implements http.Handler and is safe for
+// concurrent use.
+type Server struct {
+	mu sync.Mutex
+
+	resources map[string]*resource
+	keys      map[string]*resource // CIK to client
+	shares    map[string]*share    // share code to share
+
+	vendors    map[string]*vendor // by name
+	vendorKeys map[string]string  // vendor token or manager CIK to vendor name
+
+	// changed is closed and replaced whenever a data point is recorded,
+	// waking up pending wait calls
+	changed chan struct{}
+
+	// Now returns the current time. It can be replaced before the server
+	// is used, e.g. to make timestamps predictable.
+	Now func() time.Time
+}
+
+type resource struct {
+	rid      string
+	typ      string
+	parent   *resource
+	children []*resource
+
+	desc     map[string]interface{}
+	key      string            // CIK, for clients
+	aliases  map[string]string // alias to RID, for clients
+	points   []point           // oldest first
+	tags     []string
+	comments [][]string
+	status   string
+	created  int64
+	modified int64
+}
+
+type point struct {
+	ts    int64
+	value interface{}
+}
+
+type share struct {
+	code     string
+	resource *resource
+	meta     interface{}
+}
+
+// New returns an empty fake platform
+func New() *Server {
+	return &Server{
+		resources:  map[string]*resource{},
+		keys:       map[string]*resource{},
+		shares:     map[string]*share{},
+		vendors:    map[string]*vendor{},
+		vendorKeys: map[string]string{},
+		changed:    make(chan struct{}),
+		Now:        time.Now,
+	}
+}
+
+// NewClient creates a top level client and returns its CIK, the way a
+// portal hands out CIKs for new devices
+func (s *Server) NewClient() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	client := s.newResource(nil, "client", map[string]interface{}{"name": ""})
+	return client.key
+}
+
+// AddVendor registers a vendor with the token it authenticates with on
+// the provisioning API
+func (s *Server) AddVendor(name, token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.vendorNamed(name)
+	s.vendorKeys[token] = name
+}
+
+// ServeHTTP answers RPC and provisioning requests
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/onep:v1/rpc/process":
+		s.serveRPC(w, r)
+	case r.URL.Path == "/onep:v1/stack/alias":
+		s.serveStackAlias(w, r)
+	case r.URL.Path == "/timestamp":
+		s.serveTimestamp(w, r)
+	case strings.HasPrefix(r.URL.Path, "/provision/"):
+		s.serveProvision(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// newID returns a random 40 character hex string, the format of CIKs, RIDs
+// and share codes
+func newID() string {
+	buf := make([]byte, 20)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+func (s *Server) now() int64 {
+	return s.Now().Unix()
+}
+
+// newResource creates a resource owned by parent, or a top level client
+// when parent is nil
+func (s *Server) newResource(parent *resource, typ string, desc map[string]interface{}) *resource {
+	r := &resource{
+		rid:      newID(),
+		typ:      typ,
+		parent:   parent,
+		desc:     desc,
+		status:   "activated",
+		created:  s.now(),
+		modified: s.now(),
+	}
+	if typ == "client" {
+		r.key = newID()
+		r.aliases = map[string]string{}
+		s.keys[r.key] = r
+	}
+	if parent != nil {
+		parent.children = append(parent.children, r)
+	}
+	s.resources[r.rid] = r
+	return r
+}
+
+// drop removes r and everything it owns
+func (s *Server) drop(r *resource) {
+	for _, child := range r.children {
+		s.drop(child)
+	}
+	delete(s.resources, r.rid)
+	if r.key != "" {
+		delete(s.keys, r.key)
+	}
+	if parent := r.parent; parent != nil {
+		for i, child := range parent.children {
+			if child == r {
+				parent.children = append(parent.children[:i:i], parent.children[i+1:]...)
+				break
+			}
+		}
+		for alias, rid := range parent.aliases {
+			if rid == r.rid {
+				delete(parent.aliases, alias)
+			}
+		}
+	}
+	for code, sh := range s.shares {
+		if sh.resource == r {
+			delete(s.shares, code)
+		}
+	}
+}
+
+// owns reports whether r is owner or one of owner's descendants
+func owns(owner, r *resource) bool {
+	for ; r != nil; r = r.parent {
+		if r == owner {
+			return true
+		}
+	}
+	return false
+}
+
+// record stores a data point and wakes up pending waits
+func (s *Server) record(r *resource, ts int64, value interface{}) {
+	i := len(r.points)
+	for i > 0 && r.points[i-1].ts > ts {
+		i--
+	}
+	if i > 0 && r.points[i-1].ts == ts {
+		r.points[i-1].value = value
+	} else {
+		r.points = append(r.points, point{})
+		copy(r.points[i+1:], r.points[i:])
+		r.points[i] = point{ts, value}
+	}
+	r.modified = s.now()
+
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// clone copies src and everything it owns below parent. Aliases, comments
+// and data points are only copied when asked for.
+func (s *Server) clone(parent, src *resource, aliases, comments, historical bool) *resource {
+	desc := map[string]interface{}{}
+	for k, v := range src.desc {
+		desc[k] = v
+	}
+	r := s.newResource(parent, src.typ, desc)
+	r.tags = append([]string(nil), src.tags...)
+	if comments {
+		r.comments = append([][]string(nil), src.comments...)
+	}
+	if historical {
+		r.points = append([]point(nil), src.points...)
+	}
+
+	copies := map[string]string{}
+	for _, child := range src.children {
+		copies[child.rid] = s.clone(r, child, aliases, comments, historical).rid
+	}
+	if aliases {
+		for alias, rid := range src.aliases {
+			if copied, ok := copies[rid]; ok {
+				r.aliases[alias] = copied
+			}
+		}
+	}
+	return r
+}
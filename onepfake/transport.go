@@ -0,0 +1,30 @@
+package onepfake
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// RedirectTransport returns a transport that sends every request to the
+// server at target, e.g. the URL of an httptest.Server running the fake,
+// whatever scheme and host the request was made for. It lets code with a
+// hard coded server be tested against the fake.
+func RedirectTransport(target string) http.RoundTripper {
+	u, err := url.Parse(target)
+	if err != nil {
+		panic("onepfake: bad redirect target " + target)
+	}
+	return &redirectTransport{target: u}
+}
+
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (t *redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	redirected := req.Clone(req.Context())
+	redirected.URL.Scheme = t.target.Scheme
+	redirected.URL.Host = t.target.Host
+	redirected.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(redirected)
+}
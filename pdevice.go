@@ -106,19 +106,19 @@ func (d *Pdevice) SetMeta(meta DeviceMeta) *Pdevice {
 }
 
 type DeviceMeta struct {
-	DeviceType     string `json:"deviceType",omitempty`
-	DeviceTypeID   string `json:"deviceTypeID",omitempty`
-	DeviceTypeName string `json:"deviceTypeName",omitempty`
-	Location       string `json:"location",omitempty`
-	Timezone       string `json:"timezone",omitempty`
-	Activetime     string `json:"activetime",omitempty`
+	DeviceType     string `json:"deviceType"`
+	DeviceTypeID   string `json:"deviceTypeID"`
+	DeviceTypeName string `json:"deviceTypeName"`
+	Location       string `json:"location"`
+	Timezone       string `json:"timezone"`
+	Activetime     string `json:"activetime"`
 
 	Device struct {
-		Model  string `json:"model",omitempty`
-		Sn     string `json:"sn",omitempty`
-		Type   string `json:"type",omitempty`
-		Vendor string `json:"vendor",omitempty`
-	} `json:"device",omitempty`
+		Model  string `json:"model"`
+		Sn     string `json:"sn"`
+		Type   string `json:"type"`
+		Vendor string `json:"vendor"`
+	} `json:"device"`
 
-	ExtraField string `json:"extra_field",omitempty`
+	ExtraField string `json:"extra_field"`
 }
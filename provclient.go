@@ -0,0 +1,145 @@
+package goonep
+
+import (
+	"context"
+	"io"
+	"net/http"
+)
+
+// ProvAuth is the way a ProvisioningClient authenticates and names the
+// clients new devices are cloned from
+type ProvAuth int
+
+const (
+	// AuthVendorToken authenticates with a vendor token in the
+	// X-Exosite-Token header. Clone sources are rids.
+	AuthVendorToken ProvAuth = iota
+
+	// AuthManagerCIK authenticates with the CIK of the portal managing the
+	// vendor in the X-Exosite-CIK header. Clone sources are rids.
+	AuthManagerCIK
+
+	// AuthShareCode authenticates like AuthVendorToken but clone sources
+	// are share codes.
+	AuthShareCode
+)
+
+func (a ProvAuth) String() string {
+	switch a {
+	case AuthManagerCIK:
+		return "manager CIK"
+	case AuthShareCode:
+		return "share code"
+	}
+	return "vendor token"
+}
+
+// ProvisioningClient makes provisioning API calls with one key and auth
+// mode. The ProvModel it hands out carries both, so every function taking
+// one authenticates and sends clone sources the same way. An empty key is
+// the key of the ProvisioningClient:
+//
+//	prov := goonep.NewProvisioningClient(goonep.AuthManagerCIK, portalcik)
+//	err := goonep.Model_create(prov.Model(), "", "mymodel", clonerid, true, true, true)
+type ProvisioningClient struct {
+	client *Client
+	auth   ProvAuth
+	key    string
+}
+
+// NewProvisioningClient returns a provisioning client making its calls on c
+func (c *Client) NewProvisioningClient(auth ProvAuth, key string) *ProvisioningClient {
+	return &ProvisioningClient{client: c, auth: auth, key: key}
+}
+
+// NewProvisioningClient returns a provisioning client making its calls on
+// the default client
+func NewProvisioningClient(auth ProvAuth, key string) *ProvisioningClient {
+	return &ProvisioningClient{auth: auth, key: key}
+}
+
+// Auth returns the auth mode of the client
+func (p *ProvisioningClient) Auth() ProvAuth {
+	return p.auth
+}
+
+// Key returns the vendor token or manager CIK of the client
+func (p *ProvisioningClient) Key() string {
+	return p.key
+}
+
+// Model returns the ProvModel to pass to the provisioning functions
+func (p *ProvisioningClient) Model() ProvModel {
+	return ProvModel{
+		managebycik:       p.auth == AuthManagerCIK,
+		managebysharecode: p.auth == AuthShareCode,
+		key:               p.key,
+		client:            p.client,
+	}
+}
+
+// Models returns the models of the vendor as a ProvRestModel
+func (p *ProvisioningClient) Models() *ProvModels {
+	return &ProvModels{p.Model()}
+}
+
+// Content returns the content of model as a ProvRestModel
+func (p *ProvisioningClient) Content(model string) *ProvContent {
+	return (&ProvContent{prov: p.Model()}).ForModel(model)
+}
+
+// Groups returns the groups of the vendor as a ProvRestModel
+func (p *ProvisioningClient) Groups() *ProvGroup {
+	return &ProvGroup{prov: p.Model()}
+}
+
+// Shares returns the share codes of the vendor as a ProvRestModel
+func (p *ProvisioningClient) Shares() *ProvShare {
+	return &ProvShare{prov: p.Model()}
+}
+
+// Call makes any provisioning API call authenticated by the client and
+// returns the response body
+func (p *ProvisioningClient) Call(ctx context.Context, path, data, method string) ([]byte, error) {
+	return p.Model().request(ctx, path, p.key, data, method, http.Header{})
+}
+
+// SerialNumbers is Client.SerialNumbers authenticated by the client
+func (p *ProvisioningClient) SerialNumbers(ctx context.Context, model string) *SNIterator {
+	return p.Model().serialNumbers(ctx, model)
+}
+
+// ImportSerialNumbers is Client.ImportSerialNumbers authenticated by the
+// client
+func (p *ProvisioningClient) ImportSerialNumbers(ctx context.Context, model string, r io.Reader, opts ImportOptions) (ImportResult, error) {
+	return p.Model().importSerialNumbers(ctx, model, r, opts)
+}
+
+// ExportSerialNumbers is Client.ExportSerialNumbers authenticated by the
+// client
+func (p *ProvisioningClient) ExportSerialNumbers(ctx context.Context, model string, w io.Writer) (int, error) {
+	return p.Model().exportSerialNumbers(ctx, model, w)
+}
+
+// ContentUpload is Client.ContentUpload authenticated by the client
+func (p *ProvisioningClient) ContentUpload(ctx context.Context, model, id string, r io.Reader, size int64, mime string) (string, error) {
+	return p.Model().contentUpload(ctx, model, id, r, size, mime)
+}
+
+// CreateModel is Client.CreateModel authenticated by the client
+func (p *ProvisioningClient) CreateModel(ctx context.Context, spec ModelSpec) error {
+	m := p.Model()
+	return m.Create(ctx, "", spec.info())
+}
+
+// UpdateModel is Client.UpdateModel authenticated by the client
+func (p *ProvisioningClient) UpdateModel(ctx context.Context, spec ModelSpec) error {
+	m := p.Model()
+	return m.Update(ctx, "", spec.info())
+}
+
+// ReconcileModel is Client.ReconcileModel authenticated by the client
+func (p *ProvisioningClient) ReconcileModel(ctx context.Context, spec ModelSpec) (ModelAction, error) {
+	m := p.Model()
+	return m.Reconcile(ctx, "", spec)
+}
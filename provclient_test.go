@@ -0,0 +1,153 @@
+package goonep
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestProvisioningClientManagerCIK(t *testing.T) {
+	setupProvision(t)
+	ctx := context.Background()
+	var vendor = "MyManagedVendor" + strconv.Itoa(rand.Intn(10000000))
+	var model = "MyManagedModel" + strconv.Itoa(rand.Intn(10000000))
+
+	prov := DefaultClient.NewProvisioningClient(AuthManagerCIK, genCik())
+	if err := Vendor_register(prov.Model(), prov.Key(), vendor); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	resp, _ := Lookup(clonecik, "alias", "")
+	if err := Model_create(prov.Model(), prov.Key(), model, resp.Results[0].Body.(string), true, true, true); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if models, err := prov.Models().All(ctx, prov.Key()); err != nil || len(models) != 1 || models[0].Name != model {
+		t.Errorf("Failed: unexpected models %+v %v", models, err)
+	}
+	if err := prov.Content(model).Create(ctx, prov.Key(), ContentInfo{ID: "a.txt", Meta: "text"}); err != nil {
+		t.Errorf("Failed: %v", err)
+	}
+	if info, err := prov.Content(model).Find(ctx, prov.Key(), "a.txt"); err != nil || info.Meta != "text" {
+		t.Errorf("Failed: unexpected content %+v %v", info, err)
+	}
+	if err := prov.Groups().Create(ctx, prov.Key(), GroupInfo{Name: "g"}); err != nil {
+		t.Errorf("Failed: %v", err)
+	}
+	if _, err := prov.Call(ctx, PROVISION_MANAGE_MODEL+model, "", "GET"); err != nil {
+		t.Errorf("Failed: %v", err)
+	}
+
+	// the same key sent as a vendor token is refused
+	token := DefaultClient.NewProvisioningClient(AuthVendorToken, prov.Key())
+	if _, err := Model_list(token.Model(), token.Key()); !IsUnauthorized(err) {
+		t.Errorf("Failed: expected unauthorized, got %v", err)
+	}
+}
+
+func TestProvisioningClientShareCode(t *testing.T) {
+	setupProvision(t)
+	var model = "MySharedModel" + strconv.Itoa(rand.Intn(10000000))
+
+	resp, _ := Lookup(clonecik, "alias", "")
+	clonerid := resp.Results[0].Body.(string)
+	resp, _ = Share(portalcik, clonerid, map[string]interface{}{})
+	code := resp.Results[0].Body.(string)
+
+	prov := NewProvisioningClient(AuthShareCode, vendortoken)
+	if prov.Auth().String() != "share code" {
+		t.Errorf("Failed: unexpected auth %v", prov.Auth())
+	}
+	if err := Model_create(prov.Model(), prov.Key(), model, code, true, false, true); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	defer Model_remove(prov.Model(), prov.Key(), model)
+	if err := Model_update(prov.Model(), prov.Key(), model, code, true, true, true); err != nil {
+		t.Errorf("Failed: %v", err)
+	}
+	info, err := Model_info(prov.Model(), prov.Key(), model)
+	if err != nil || info.Code != code || info.Rid != clonerid || !info.Comments {
+		t.Errorf("Failed: unexpected model %+v %v", info, err)
+	}
+
+	// a rid is no share code
+	if err := Model_create(prov.Model(), prov.Key(), model+"x", clonerid, true, true, true); !IsPreconditionFailed(err) {
+		t.Errorf("Failed: expected precondition failed, got %v", err)
+	}
+}
+
+func TestProvisioningClientHelpers(t *testing.T) {
+	setupProvision(t)
+	ctx := context.Background()
+
+	resp, _ := Lookup(clonecik, "alias", "")
+	clonerid := resp.Results[0].Body.(string)
+	resp, _ = Share(portalcik, clonerid, map[string]interface{}{})
+	code := resp.Results[0].Body.(string)
+
+	// the client's own vendor token is wrong, only the ProvisioningClient's
+	// key gets the calls through
+	client := *DefaultClient
+	client.VendorToken = genCik()
+	client.Models = nil
+
+	managerCIK := genCik()
+	vendor := "MyHelperVendor" + strconv.Itoa(rand.Intn(10000000))
+	if err := Vendor_register(client.NewProvisioningClient(AuthManagerCIK, managerCIK).Model(), "", vendor); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	for _, test := range []struct {
+		prov *ProvisioningClient
+		spec ModelSpec
+	}{
+		{client.NewProvisioningClient(AuthManagerCIK, managerCIK), ModelSpec{Rid: clonerid, Aliases: true}},
+		{client.NewProvisioningClient(AuthShareCode, vendortoken), ModelSpec{Code: code, Aliases: true}},
+	} {
+		prov := test.prov
+		spec := test.spec
+		spec.Name = "MyHelperModel" + strconv.Itoa(rand.Intn(10000000))
+
+		if err := prov.CreateModel(ctx, spec); err != nil {
+			t.Fatalf("Failed: %v: %v", prov.Auth(), err)
+		}
+		defer Model_remove(prov.Model(), "", spec.Name)
+		spec.Comments = true
+		if action, err := prov.ReconcileModel(ctx, spec); err != nil || action != ModelUpdated {
+			t.Errorf("Failed: %v: %v %v", prov.Auth(), action, err)
+		}
+		if info, err := Model_info(prov.Model(), "", spec.Name); err != nil || !info.Comments || info.Rid != clonerid {
+			t.Errorf("Failed: %v: unexpected model %+v %v", prov.Auth(), info, err)
+		}
+
+		result, err := prov.ImportSerialNumbers(ctx, spec.Name, strings.NewReader("sn\nsn1\nsn2\n"), ImportOptions{})
+		if err != nil || result.Imported != 2 {
+			t.Errorf("Failed: %v: unexpected import %+v %v", prov.Auth(), result, err)
+		}
+		var sns []string
+		it := prov.SerialNumbers(ctx, spec.Name)
+		for it.Next() {
+			sns = append(sns, it.Entry().SN)
+		}
+		if err := it.Err(); err != nil || len(sns) != 2 {
+			t.Errorf("Failed: %v: unexpected serial numbers %v %v", prov.Auth(), sns, err)
+		}
+		var exported bytes.Buffer
+		if n, err := prov.ExportSerialNumbers(ctx, spec.Name, &exported); err != nil || n != 2 {
+			t.Errorf("Failed: %v: exported %d: %v", prov.Auth(), n, err)
+		}
+		m := prov.Model()
+		if found := m.FindSerialNumber(spec.Name, "sn1"); found.SN != "sn1" {
+			t.Errorf("Failed: %v: sn1 not found: %+v", prov.Auth(), found)
+		}
+
+		if err := prov.Content(spec.Name).Create(ctx, "", ContentInfo{ID: "a.txt", Meta: "text"}); err != nil {
+			t.Fatalf("Failed: %v: %v", prov.Auth(), err)
+		}
+		if _, err := prov.ContentUpload(ctx, spec.Name, "a.txt", strings.NewReader("hello"), 5, "text/plain"); err != nil {
+			t.Errorf("Failed: %v: %v", prov.Auth(), err)
+		}
+	}
+}
@@ -3,10 +3,11 @@
 package goonep
 
 import (
+	"context"
 	//	"fmt"
-	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
@@ -22,16 +23,10 @@ var PROVISION_DOWNLOAD = PROVISION_BASE + "/download"
 var PROVISION_MANAGE = PROVISION_BASE + "/manage"
 var PROVISION_MANAGE_MODEL = PROVISION_MANAGE + "/model/"
 var PROVISION_MANAGE_CONTENT = PROVISION_MANAGE + "/content/"
+var PROVISION_MANAGE_GROUP = PROVISION_MANAGE + "/group/"
+var PROVISION_MANAGE_SHARE = PROVISION_MANAGE + "/share/"
 var PROVISION_REGISTER = PROVISION_BASE + "/register"
 
-var Pool struct {
-	Models map[string]*ProvModel
-}
-
-type ProvContent struct{}
-
-type ProvGroup struct{}
-
 type ProvModel struct {
 	RawData string
 
@@ -45,47 +40,65 @@ type ProvModel struct {
 	managebycik       bool
 	managebysharecode bool
 	url               string
+	key               string
+	client            *Client
 }
 
-func (m *ProvModel) GetPath() string {
-	return "manage/model"
-}
-
-// Find is a helper function for finding model with characteristics contained in string argument
-func (m *ProvModel) Find(modelName, id string) ProvModel {
-
-	if Pool.Models[id] != nil {
-		return *Pool.Models[id]
-	}
-
-	fetchedModel := ProvModel{}
-
+// FindSerialNumber is a helper function for finding the serial number id of
+// model modelName. It returns an empty ProvModel when there is none. Lookups
+// are cached in the Models cache of the model's client.
+func (m *ProvModel) FindSerialNumber(modelName, id string) ProvModel {
 	if len(id) <= 0 {
-		log.Printf("Try find a non-sense ID: %d ", id)
+		log.Printf("Try find a non-sense ID: %s ", id)
 		return ProvModel{}
 	}
 
-	var headers = http.Header{}
-	result, err := ProvCall(m.GetPath()+"/"+modelName+"/"+id, VendorToken, "", "GET", false, headers)
+	client := m.provClient()
+	key := m.key
+	if key == "" {
+		key = client.vendorToken()
+	}
+	fetch := func() (ProvModel, bool, error) {
+		fetchedModel := ProvModel{}
+		var headers = http.Header{}
+		result, err := m.request(context.Background(), PROVISION_MANAGE_MODEL+url.PathEscape(modelName)+"/"+url.PathEscape(id), key, "", "GET", headers)
+		if IsNotFound(err) {
+			return fetchedModel, false, nil
+		}
+		if err != nil {
+			return fetchedModel, false, err
+		}
+
+		rawData := strings.Trim(string(result), "\r\n")
+
+		fetchedModel.Parse(rawData)
+		fetchedModel.SN = id
+		fetchedModel.TimeStamp = time.Now().Unix()
+		return fetchedModel, true, nil
+	}
 
+	var fetchedModel ProvModel
+	var err error
+	if cache := client.Models; cache != nil {
+		cacheKey := CacheKey{Host: client.provisionHost(), Vendor: key, Model: modelName, SN: id}
+		fetchedModel, _, err = cache.Lookup(cacheKey, fetch)
+	} else {
+		fetchedModel, _, err = fetch()
+	}
 	if err != nil {
 		log.Printf("Finding model(id: %s) met some error %v", id, err)
-		return fetchedModel
-	}
-
-	rawData := strings.Trim(string(result.([]uint8)), "\r\n")
-
-	if rawData == "HTTP/1.1 404 Not Found" {
-		return fetchedModel
+		return ProvModel{}
 	}
-
-	fetchedModel.Parse(rawData)
-	fetchedModel.SN = id
-	fetchedModel.TimeStamp = time.Now().Unix()
-
 	return fetchedModel
 }
 
+// Find is FindSerialNumber under its old name.
+//
+// Deprecated: use FindSerialNumber, or ProvModels.Find to look up a model.
+func (m *ProvModel) Find(modelName, id string) ProvModel {
+	return m.FindSerialNumber(modelName, id)
+}
+
 func (m *ProvModel) Parse(RawData string) {
 
 	if len(RawData) <= 0 {
@@ -122,53 +135,25 @@ func (m *ProvModel) Bytes() []byte {
 	return []byte(m.RawData)
 }
 
-type ProvShare struct{}
-
-var Provision struct {
-	Manage struct {
-		Content ProvContent
-		Group   ProvGroup
-		Model   ProvModel
-		Share   ProvShare
-	}
-
-	Admin struct {
-		Auth ProvModel
-	}
-
-	Register ProvModel
+// ProvCall is a helper function that carries out HTTP requests for Provisioning API calls.
+// Responses with a status other than 2xx are returned with a *ProvisionError.
+func (c *Client) ProvCall(path, key, data, method string, managebycik bool, extra_headers http.Header) (interface{}, error) {
+	return c.ProvCallContext(context.Background(), path, key, data, method, managebycik, extra_headers)
 }
 
-type ProvRestModel interface {
-
-	// GetPath retrive the URL path for each different models
-	GetPath() string
-
-	Create(attr *interface{}) Response
-
-	Find(id string) Response
-	All() Response
-
-	Update(attr *interface{}) Response
-	Delete(attr *interface{}) Response
-}
-
-// ProvCall is a helper function that carries out HTTP requests for Provisioning API calls
-func ProvCall(path, key, data, method string, managebycik bool, extra_headers http.Header) (interface{}, error) {
-	client := &http.Client{}
-
-	var serverUrl = ""
-	serverUrl = "https://m2.exosite.com"
-
-	//fmt.Printf(serverUrl + path + "\n\n")
-	req, _ := http.NewRequest(method, serverUrl+path, strings.NewReader(data))
+// ProvCallContext is like ProvCall but gives up when ctx is done
+func (c *Client) ProvCallContext(ctx context.Context, path, key, data, method string, managebycik bool, extra_headers http.Header) (interface{}, error) {
+	req, err := http.NewRequest(method, c.provisionURL(path), strings.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
 	req.Header = extra_headers
-	if managebycik {
-		req.Header.Add("X-Exosite-CIK", key)
-	} else {
-		req.Header.Add("X-Exosite-Token", key)
+	if req.Header == nil {
+		req.Header = http.Header{}
 	}
-	if method == "POST" {
+	setProvisionAuth(req.Header, key, managebycik)
+	// a form body needs its content type whatever the method
+	if (method == "POST" || data != "") && req.Header.Get("Content-Type") == "" {
 		req.Header.Add("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
 	}
 	req.Header.Add("Accept", "text/plain, text/csv, application/x-www-form-urlencoded")
@@ -177,232 +162,666 @@ func ProvCall(path, key, data, method string, managebycik bool, extra_headers ht
 	// reqdump, _ := httputil.DumpRequestOut(req, true)
 	// fmt.Printf("\r\n\r\n" + string(reqdump) + "\r\n\r\n")
 
-	resp, err := client.Do(req)
-
+	resp, body, err := c.do(ctx, req, method == "GET")
 	if err != nil {
-		return resp, err
+		return body, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return body, &ProvisionError{StatusCode: resp.StatusCode, Method: method, Path: path, Body: string(body)}
 	}
 
-	defer resp.Body.Close()
+	return body, nil
+}
 
-	body, readErr := ioutil.ReadAll(resp.Body)
-	if readErr != nil {
-		return body, readErr
+// setProvisionAuth authenticates a provisioning request with a manager CIK
+// or a vendor token
+func setProvisionAuth(header http.Header, key string, managebycik bool) {
+	if managebycik {
+		header.Set("X-Exosite-CIK", key)
+	} else {
+		header.Set("X-Exosite-Token", key)
 	}
+}
 
+// ProvCall calls Client.ProvCall on the default client
+func ProvCall(path, key, data, method string, managebycik bool, extra_headers http.Header) (interface{}, error) {
+	return defaultClient().ProvCall(path, key, data, method, managebycik, extra_headers)
+}
+
+// ProvCallContext is like ProvCall but gives up when ctx is done
+func ProvCallContext(ctx context.Context, path, key, data, method string, managebycik bool, extra_headers http.Header) (interface{}, error) {
+	return defaultClient().ProvCallContext(ctx, path, key, data, method, managebycik, extra_headers)
+}
+
+// provRequest is ProvCallContext returning the body as bytes
+func provRequest(ctx context.Context, path, key, data, method string, managebycik bool, extra_headers http.Header) ([]byte, error) {
+	return defaultClient().provRequest(ctx, path, key, data, method, managebycik, extra_headers)
+}
+
+func (c *Client) provRequest(ctx context.Context, path, key, data, method string, managebycik bool, extra_headers http.Header) ([]byte, error) {
+	result, err := c.ProvCallContext(ctx, path, key, data, method, managebycik, extra_headers)
+	if err != nil {
+		return nil, err
+	}
+	body, _ := result.([]byte)
 	return body, nil
 }
 
+// request is provRequest on the client of the ProvisioningClient the model
+// came from, authenticating the way the model is managed. An empty key is
+// the key of that ProvisioningClient.
+func (m ProvModel) request(ctx context.Context, path, key, data, method string, extra_headers http.Header) ([]byte, error) {
+	if key == "" {
+		key = m.key
+	}
+	return m.provClient().provRequest(ctx, path, key, data, method, m.managebycik, extra_headers)
+}
+
+// provClient returns the client the model makes its calls on
+func (m ProvModel) provClient() *Client {
+	if m.client == nil {
+		return defaultClient()
+	}
+	return m.client
+}
+
 // content_create implements POST to /provision/manage/content/<MODEL>/
-func Content_create(provModel ProvModel, key, model, contentid, meta string, protect bool) (interface{}, error) {
-	var data = "id=" + contentid + "&meta=" + meta
+func Content_create(provModel ProvModel, key, model, contentid, meta string, protect bool) error {
+	return Content_createContext(context.Background(), provModel, key, model, contentid, meta, protect)
+}
+
+// Content_createContext is like Content_create but gives up when ctx is done
+func Content_createContext(ctx context.Context, provModel ProvModel, key, model, contentid, meta string, protect bool) error {
+	var params = url.Values{}
+	params.Set("id", contentid)
+	params.Set("meta", meta)
 	if protect != false {
-		data = data + "&protected=true"
+		params.Set("protected", "true")
 	}
-	var path = PROVISION_MANAGE_CONTENT + model + "/"
+	var path = PROVISION_MANAGE_CONTENT + url.PathEscape(model) + "/"
 	var headers = http.Header{}
-	return ProvCall(path, key, data, "POST", provModel.managebycik, headers)
+	_, err := provModel.request(ctx, path, key, params.Encode(), "POST", headers)
+	return err
 }
 
 // content_download implements GET to /provision/download
-func Content_download(provModel ProvModel, cik, vendor, model, contentid string) (interface{}, error) {
-	var data = "vendor=" + vendor + "&model=" + model + "&id=" + contentid
+func Content_download(provModel ProvModel, cik, vendor, model, contentid string) ([]byte, error) {
+	return Content_downloadContext(context.Background(), provModel, cik, vendor, model, contentid)
+}
+
+// Content_downloadContext is like Content_download but gives up when ctx is done
+func Content_downloadContext(ctx context.Context, provModel ProvModel, cik, vendor, model, contentid string) ([]byte, error) {
+	var params = url.Values{}
+	params.Set("vendor", vendor)
+	params.Set("model", model)
+	params.Set("id", contentid)
 	var headers = http.Header{}
 	headers.Add("Accept", "*")
-	return ProvCall(PROVISION_DOWNLOAD, cik, data, "GET", provModel.managebycik, headers)
+	// devices always authenticate with their CIK, however the model is managed
+	return provModel.provClient().provRequest(ctx, PROVISION_DOWNLOAD+"?"+params.Encode(), cik, "", "GET", true, headers)
 }
 
 // content_info implements GET to /provision/manage/content/<MODEL>/<CONTENT_ID>
-// or GET to /provision/download
-func Content_info(provModel ProvModel, key, model, contentid, vendor string) (interface{}, error) {
+// or GET to /provision/download, where key is the CIK of a device of
+// vendor
+func Content_info(provModel ProvModel, key, model, contentid, vendor string) (ContentInfo, error) {
+	return Content_infoContext(context.Background(), provModel, key, model, contentid, vendor)
+}
+
+// Content_infoContext is like Content_info but gives up when ctx is done
+func Content_infoContext(ctx context.Context, provModel ProvModel, key, model, contentid, vendor string) (ContentInfo, error) {
 	var headers = http.Header{}
+	var body []byte
+	var err error
 	if vendor == "" {
-		var path = PROVISION_MANAGE_CONTENT + model + "/" + contentid
-		return ProvCall(path, key, "", "GET", provModel.managebycik, headers)
+		var path = PROVISION_MANAGE_CONTENT + url.PathEscape(model) + "/" + url.PathEscape(contentid)
+		body, err = provModel.request(ctx, path, key, "", "GET", headers)
 	} else {
-		var data = "vendor=" + vendor + "&model=" + model + "&info=true"
-		return ProvCall(PROVISION_DOWNLOAD, key, data, "GET", provModel.managebycik, headers)
+		var params = url.Values{}
+		params.Set("vendor", vendor)
+		params.Set("model", model)
+		params.Set("id", contentid)
+		params.Set("info", "true")
+		// key is the CIK of a device here
+		body, err = provModel.provClient().provRequest(ctx, PROVISION_DOWNLOAD+"?"+params.Encode(), key, "", "GET", true, headers)
+	}
+	if err != nil {
+		return ContentInfo{}, err
 	}
+	return ParseContentInfo(contentid, body)
+}
+
+// content_list implements GET to /provision/manage/content/<MODEL>/ followed
+// by a content_info for each item listed
+func Content_list(provModel ProvModel, key, model string) ([]ContentInfo, error) {
+	return Content_listContext(context.Background(), provModel, key, model)
 }
 
-// content_list implements GET to /provision/manage/content/<MODEL>/
-func Content_list(provModel ProvModel, key, model string) (interface{}, error) {
-	var path = PROVISION_MANAGE_CONTENT + model + "/"
+// Content_listContext is like Content_list but gives up when ctx is done
+func Content_listContext(ctx context.Context, provModel ProvModel, key, model string) ([]ContentInfo, error) {
+	var path = PROVISION_MANAGE_CONTENT + url.PathEscape(model) + "/"
 	var headers = http.Header{}
-	return ProvCall(path, key, "", "GET", provModel.managebycik, headers)
+	body, err := provModel.request(ctx, path, key, "", "GET", headers)
+	if err != nil {
+		return nil, err
+	}
+	var contents []ContentInfo
+	for _, id := range ParseList(body) {
+		info, err := Content_infoContext(ctx, provModel, key, model, id, "")
+		if err != nil {
+			return nil, err
+		}
+		contents = append(contents, info)
+	}
+	return contents, nil
 }
 
 // content_remove implements DELETE to /provision/manage/content/<MODEL>/<CONTENT_ID>
-func Content_remove(provModel ProvModel, key, model, contentid string) (interface{}, error) {
+func Content_remove(provModel ProvModel, key, model, contentid string) error {
+	return Content_removeContext(context.Background(), provModel, key, model, contentid)
+}
+
+// Content_removeContext is like Content_remove but gives up when ctx is done
+func Content_removeContext(ctx context.Context, provModel ProvModel, key, model, contentid string) error {
 	var headers = http.Header{}
-	var path = PROVISION_MANAGE_CONTENT + model + "/" + contentid
-	return ProvCall(path, key, "", "DELETE", provModel.managebycik, headers)
+	var path = PROVISION_MANAGE_CONTENT + url.PathEscape(model) + "/" + url.PathEscape(contentid)
+	_, err := provModel.request(ctx, path, key, "", "DELETE", headers)
+	return err
+}
+
+// content_update implements PUT to /provision/manage/content/<MODEL>/<CONTENT_ID>
+func Content_update(provModel ProvModel, key, model, contentid, meta string, protect bool) error {
+	return Content_updateContext(context.Background(), provModel, key, model, contentid, meta, protect)
+}
+
+// Content_updateContext is like Content_update but gives up when ctx is done
+func Content_updateContext(ctx context.Context, provModel ProvModel, key, model, contentid, meta string, protect bool) error {
+	var headers = http.Header{}
+	var params = url.Values{}
+	params.Set("meta", meta)
+	params.Set("protected", strconv.FormatBool(protect))
+	var path = PROVISION_MANAGE_CONTENT + url.PathEscape(model) + "/" + url.PathEscape(contentid)
+	_, err := provModel.request(ctx, path, key, params.Encode(), "PUT", headers)
+	return err
 }
 
 // content_upload implements POST to /provision/manage/content/<MODEL>/<CONTENT_ID>
-func Content_upload(provModel ProvModel, key, model, contentid, data, mimetype string) (interface{}, error) {
+func Content_upload(provModel ProvModel, key, model, contentid, data, mimetype string) error {
+	return Content_uploadContext(context.Background(), provModel, key, model, contentid, data, mimetype)
+}
+
+// Content_uploadContext is like Content_upload but gives up when ctx is done
+func Content_uploadContext(ctx context.Context, provModel ProvModel, key, model, contentid, data, mimetype string) error {
 	var headers = http.Header{}
 	headers.Add("Content-Type", mimetype)
-	var path = PROVISION_MANAGE_CONTENT + model + "/" + contentid
-	return ProvCall(path, key, data, "POST", provModel.managebycik, headers)
+	var path = PROVISION_MANAGE_CONTENT + url.PathEscape(model) + "/" + url.PathEscape(contentid)
+	_, err := provModel.request(ctx, path, key, data, "POST", headers)
+	return err
 }
 
-// model_create implements POST to /provision/manage/model/
-func Model_create(provModel ProvModel, key, model, sharecode string, aliases, comments, historical bool) (interface{}, error) {
+// group_create implements POST to /provision/manage/group/
+func Group_create(provModel ProvModel, key, group, meta string, members []string) error {
+	return Group_createContext(context.Background(), provModel, key, group, meta, members)
+}
+
+// Group_createContext is like Group_create but gives up when ctx is done
+func Group_createContext(ctx context.Context, provModel ProvModel, key, group, meta string, members []string) error {
 	var headers = http.Header{}
-	var data = "model=" + model
-	if provModel.managebysharecode {
-		data = data + "&code=" + sharecode
-	} else {
-		data = data + "&rid=" + sharecode
+	var params = url.Values{}
+	params.Set("name", group)
+	params.Set("meta", meta)
+	for i := range members {
+		params.Add("members[]", members[i])
+	}
+	_, err := provModel.request(ctx, PROVISION_MANAGE_GROUP, key, params.Encode(), "POST", headers)
+	return err
+}
+
+// group_info implements GET to /provision/manage/group/<GROUP>
+func Group_info(provModel ProvModel, key, group string) (GroupInfo, error) {
+	return Group_infoContext(context.Background(), provModel, key, group)
+}
+
+// Group_infoContext is like Group_info but gives up when ctx is done
+func Group_infoContext(ctx context.Context, provModel ProvModel, key, group string) (GroupInfo, error) {
+	var headers = http.Header{}
+	body, err := provModel.request(ctx, PROVISION_MANAGE_GROUP+url.PathEscape(group), key, "", "GET", headers)
+	if err != nil {
+		return GroupInfo{}, err
 	}
-	if aliases == false {
-		data = data + "&options[]=noaliases"
+	return ParseGroupInfo(group, body)
+}
+
+// group_list implements GET to /provision/manage/group/
+func Group_list(provModel ProvModel, key string) ([]string, error) {
+	return Group_listContext(context.Background(), provModel, key)
+}
+
+// Group_listContext is like Group_list but gives up when ctx is done
+func Group_listContext(ctx context.Context, provModel ProvModel, key string) ([]string, error) {
+	var headers = http.Header{}
+	body, err := provModel.request(ctx, PROVISION_MANAGE_GROUP, key, "", "GET", headers)
+	if err != nil {
+		return nil, err
 	}
-	if comments == false {
-		data = data + "&options[]=nocomments"
+	return ParseList(body), nil
+}
+
+// group_remove implements DELETE to /provision/manage/group/<GROUP>
+func Group_remove(provModel ProvModel, key, group string) error {
+	return Group_removeContext(context.Background(), provModel, key, group)
+}
+
+// Group_removeContext is like Group_remove but gives up when ctx is done
+func Group_removeContext(ctx context.Context, provModel ProvModel, key, group string) error {
+	var headers = http.Header{}
+	_, err := provModel.request(ctx, PROVISION_MANAGE_GROUP+url.PathEscape(group), key, "", "DELETE", headers)
+	return err
+}
+
+// group_update implements PUT to /provision/manage/group/<GROUP>, replacing
+// the group's meta and members
+func Group_update(provModel ProvModel, key, group, meta string, members []string) error {
+	return Group_updateContext(context.Background(), provModel, key, group, meta, members)
+}
+
+// Group_updateContext is like Group_update but gives up when ctx is done
+func Group_updateContext(ctx context.Context, provModel ProvModel, key, group, meta string, members []string) error {
+	var headers = http.Header{}
+	var params = url.Values{}
+	params.Set("meta", meta)
+	for i := range members {
+		params.Add("members[]", members[i])
 	}
-	if historical == false {
-		data = data + "&options[]=nohistorical"
+	_, err := provModel.request(ctx, PROVISION_MANAGE_GROUP+url.PathEscape(group), key, params.Encode(), "PUT", headers)
+	return err
+}
+
+// model_create implements POST to /provision/manage/model/
+func Model_create(provModel ProvModel, key, model, sharecode string, aliases, comments, historical bool) error {
+	return Model_createContext(context.Background(), provModel, key, model, sharecode, aliases, comments, historical)
+}
+
+// Model_createContext is like Model_create but gives up when ctx is done
+func Model_createContext(ctx context.Context, provModel ProvModel, key, model, sharecode string, aliases, comments, historical bool) error {
+	return provModel.Create(ctx, key, provModel.modelSpec(model, sharecode, aliases, comments, historical).info())
+}
+
+// modelSpec describes a model cloned from sharecode, which is a share code
+// when managing by share code and a rid otherwise
+func (m ProvModel) modelSpec(model, sharecode string, aliases, comments, historical bool) ModelSpec {
+	spec := ModelSpec{Name: model, Aliases: aliases, Comments: comments, Historical: historical}
+	if m.managebysharecode {
+		spec.Code = sharecode
+	} else {
+		spec.Rid = sharecode
 	}
-	return ProvCall(PROVISION_MANAGE_MODEL, key, data, "POST", provModel.managebycik, headers)
+	return spec
 }
 
 // model_info implements GET to provision/manage/model/<MODEL>
-func Model_info(provModel ProvModel, key, model string) (interface{}, error) {
+func Model_info(provModel ProvModel, key, model string) (ModelInfo, error) {
+	return Model_infoContext(context.Background(), provModel, key, model)
+}
+
+// Model_infoContext is like Model_info but gives up when ctx is done
+func Model_infoContext(ctx context.Context, provModel ProvModel, key, model string) (ModelInfo, error) {
 	var headers = http.Header{}
-	return ProvCall(PROVISION_MANAGE_MODEL+model, key, "", "GET", provModel.managebycik, headers)
+	body, err := provModel.request(ctx, PROVISION_MANAGE_MODEL+url.PathEscape(model), key, "", "GET", headers)
+	if err != nil {
+		return ModelInfo{}, err
+	}
+	return ParseModelInfo(model, body)
 }
 
 // model_list implements GET to /provision/manage/model/
-func Model_list(provModel ProvModel, key string) (interface{}, error) {
+func Model_list(provModel ProvModel, key string) ([]string, error) {
+	return Model_listContext(context.Background(), provModel, key)
+}
+
+// Model_listContext is like Model_list but gives up when ctx is done
+func Model_listContext(ctx context.Context, provModel ProvModel, key string) ([]string, error) {
 	var headers = http.Header{}
-	return ProvCall(PROVISION_MANAGE_MODEL, key, "", "GET", provModel.managebycik, headers)
+	body, err := provModel.request(ctx, PROVISION_MANAGE_MODEL, key, "", "GET", headers)
+	if err != nil {
+		return nil, err
+	}
+	return ParseList(body), nil
 }
 
 // model_remove implements DELETE to /provision/manage/model/<MODEL>
-func Model_remove(provModel ProvModel, key, model string) (interface{}, error) {
-	var headers = http.Header{}
-	var data = "delete=true&model=" + model + "&confirm=true"
-	var path = PROVISION_MANAGE_MODEL + model
-	return ProvCall(path, key, data, "DELETE", provModel.managebycik, headers)
+func Model_remove(provModel ProvModel, key, model string) error {
+	return Model_removeContext(context.Background(), provModel, key, model)
 }
 
-// model_update implements PUT to /provision/manage/model/<MODEL>
-func Model_update(provModel ProvModel, key, model, clonerid string, aliases, comments, historical bool) (interface{}, error) {
+// Model_removeContext is like Model_remove but gives up when ctx is done
+func Model_removeContext(ctx context.Context, provModel ProvModel, key, model string) error {
 	var headers = http.Header{}
-	var data = "rid=" + clonerid
-	var path = PROVISION_MANAGE_MODEL + model
-	return ProvCall(path, key, data, "PUT", provModel.managebycik, headers)
+	var params = url.Values{}
+	params.Set("delete", "true")
+	params.Set("model", model)
+	params.Set("confirm", "true")
+	var path = PROVISION_MANAGE_MODEL + url.PathEscape(model)
+	defer provModel.invalidateModel(model)
+	_, err := provModel.request(ctx, path, key, params.Encode(), "DELETE", headers)
+	return err
 }
 
-// serialnumber_activate implements POST to /provision/activate
-func Serialnumber_activate(provModel ProvModel, model, serialnumber, vendor string) (interface{}, error) {
+// model_update implements PUT to /provision/manage/model/<MODEL>, changing
+// the clone source and options of a model like Model_create sets them
+func Model_update(provModel ProvModel, key, model, sharecode string, aliases, comments, historical bool) error {
+	return Model_updateContext(context.Background(), provModel, key, model, sharecode, aliases, comments, historical)
+}
+
+// Model_updateContext is like Model_update but gives up when ctx is done
+func Model_updateContext(ctx context.Context, provModel ProvModel, key, model, sharecode string, aliases, comments, historical bool) error {
+	return provModel.Update(ctx, key, provModel.modelSpec(model, sharecode, aliases, comments, historical).info())
+}
+
+// serialnumber_activate implements POST to /provision/activate and returns
+// the device's CIK
+func Serialnumber_activate(provModel ProvModel, model, serialnumber, vendor string) (string, error) {
+	return Serialnumber_activateContext(context.Background(), provModel, model, serialnumber, vendor)
+}
+
+// Serialnumber_activateContext is like Serialnumber_activate but gives up when ctx is done
+func Serialnumber_activateContext(ctx context.Context, provModel ProvModel, model, serialnumber, vendor string) (string, error) {
 	var headers = http.Header{}
-	var data = "vendor=" + vendor + "&model=" + model + "&sn=" + serialnumber
-	return ProvCall(PROVISION_ACTIVATE, "", data, "POST", provModel.managebycik, headers)
+	var params = url.Values{}
+	params.Set("vendor", vendor)
+	params.Set("model", model)
+	params.Set("sn", serialnumber)
+	defer provModel.invalidate(model, serialnumber)
+	// activating is not authenticated
+	body, err := provModel.provClient().provRequest(ctx, PROVISION_ACTIVATE, "", params.Encode(), "POST", false, headers)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(body)), nil
 }
 
 // serialnumber_add implements POST to /provision/manage/model/<MODEL>/
-func Serialnumber_add(provModel ProvModel, key, model, sn string) (interface{}, error) {
+func Serialnumber_add(provModel ProvModel, key, model, sn string) error {
+	return Serialnumber_addContext(context.Background(), provModel, key, model, sn)
+}
+
+// Serialnumber_addContext is like Serialnumber_add but gives up when ctx is done
+func Serialnumber_addContext(ctx context.Context, provModel ProvModel, key, model, sn string) error {
 	var headers = http.Header{}
-	var data = "add=true&sn=" + sn
-	var path = PROVISION_MANAGE_MODEL + model + "/"
-	return ProvCall(path, key, data, "POST", provModel.managebycik, headers)
+	var params = url.Values{}
+	params.Set("add", "true")
+	params.Set("sn", sn)
+	var path = PROVISION_MANAGE_MODEL + url.PathEscape(model) + "/"
+	defer provModel.invalidate(model, sn)
+	_, err := provModel.request(ctx, path, key, params.Encode(), "POST", headers)
+	return err
 }
 
 // serialnumber_add_batch implements POST to /provision/manage/model/<MODEL>/
-func Serialnumber_add_batch(provModel ProvModel, key, model string, sns []string) (interface{}, error) {
+func Serialnumber_add_batch(provModel ProvModel, key, model string, sns []string) error {
+	return Serialnumber_add_batchContext(context.Background(), provModel, key, model, sns)
+}
+
+// Serialnumber_add_batchContext is like Serialnumber_add_batch but gives up when ctx is done
+func Serialnumber_add_batchContext(ctx context.Context, provModel ProvModel, key, model string, sns []string) error {
 	var headers = http.Header{}
-	var data = "add=true"
+	var params = url.Values{}
+	params.Set("add", "true")
 	for i := range sns {
-		data = data + "&sn[]=" + sns[i]
+		params.Add("sn[]", sns[i])
 	}
-	var path = PROVISION_MANAGE_MODEL + model + "/"
-	return ProvCall(path, key, data, "POST", provModel.managebycik, headers)
+	var path = PROVISION_MANAGE_MODEL + url.PathEscape(model) + "/"
+	defer provModel.invalidate(model, sns...)
+	_, err := provModel.request(ctx, path, key, params.Encode(), "POST", headers)
+	return err
 }
 
 // serialnumber_disable implements POST to /provision/manage/model/<MODEL>/<SN>
-func Serialnumber_disable(provModel ProvModel, key, model, serialnumber string) (interface{}, error) {
+func Serialnumber_disable(provModel ProvModel, key, model, serialnumber string) error {
+	return Serialnumber_disableContext(context.Background(), provModel, key, model, serialnumber)
+}
+
+// Serialnumber_disableContext is like Serialnumber_disable but gives up when ctx is done
+func Serialnumber_disableContext(ctx context.Context, provModel ProvModel, key, model, serialnumber string) error {
 	var headers = http.Header{}
-	var data = "disable=true"
-	var path = PROVISION_MANAGE_MODEL + model + "/" + serialnumber
-	return ProvCall(path, key, data, "POST", provModel.managebycik, headers)
+	var params = url.Values{}
+	params.Set("disable", "true")
+	var path = PROVISION_MANAGE_MODEL + url.PathEscape(model) + "/" + url.PathEscape(serialnumber)
+	defer provModel.invalidate(model, serialnumber)
+	_, err := provModel.request(ctx, path, key, params.Encode(), "POST", headers)
+	return err
 }
 
 // serialnumber_enable implements POST to /provision/manage/model/<MODEL>/<SN>
-func Serialnumber_enable(provModel ProvModel, key, model, serialnumber, owner string) (interface{}, error) {
+func Serialnumber_enable(provModel ProvModel, key, model, serialnumber, owner string) error {
+	return Serialnumber_enableContext(context.Background(), provModel, key, model, serialnumber, owner)
+}
+
+// Serialnumber_enableContext is like Serialnumber_enable but gives up when ctx is done
+func Serialnumber_enableContext(ctx context.Context, provModel ProvModel, key, model, serialnumber, owner string) error {
 	var headers = http.Header{}
-	var data = "enable=true&owner=" + owner
-	var path = PROVISION_MANAGE_MODEL + model + "/" + serialnumber
-	return ProvCall(path, key, data, "POST", provModel.managebycik, headers)
+	var params = url.Values{}
+	params.Set("enable", "true")
+	params.Set("owner", owner)
+	var path = PROVISION_MANAGE_MODEL + url.PathEscape(model) + "/" + url.PathEscape(serialnumber)
+	defer provModel.invalidate(model, serialnumber)
+	_, err := provModel.request(ctx, path, key, params.Encode(), "POST", headers)
+	return err
 }
 
 // serialnumber_info implements GET to /provision/manage/model/<MODEL>/<SN>
-func Serialnumber_info(provModel ProvModel, key, model, serialnumber string) (interface{}, error) {
+func Serialnumber_info(provModel ProvModel, key, model, serialnumber string) (SerialNumberEntry, error) {
+	return Serialnumber_infoContext(context.Background(), provModel, key, model, serialnumber)
+}
+
+// Serialnumber_infoContext is like Serialnumber_info but gives up when ctx is done
+func Serialnumber_infoContext(ctx context.Context, provModel ProvModel, key, model, serialnumber string) (SerialNumberEntry, error) {
 	var headers = http.Header{}
-	var path = PROVISION_MANAGE_MODEL + model + "/" + serialnumber
-	return ProvCall(path, key, "", "GET", provModel.managebycik, headers)
+	var path = PROVISION_MANAGE_MODEL + url.PathEscape(model) + "/" + url.PathEscape(serialnumber)
+	body, err := provModel.request(ctx, path, key, "", "GET", headers)
+	if err != nil {
+		return SerialNumberEntry{}, err
+	}
+	return ParseSerialNumberInfo(serialnumber, body)
 }
 
 // serialnumber_list implements GET to /provision/manage/model/<MODEL>/
-func Serialnumber_list(provModel ProvModel, key, model string, offset, limit int) (interface{}, error) {
+func Serialnumber_list(provModel ProvModel, key, model string, offset, limit int) ([]SerialNumberEntry, error) {
+	return Serialnumber_listContext(context.Background(), provModel, key, model, offset, limit)
+}
+
+// Serialnumber_listContext is like Serialnumber_list but gives up when ctx is done
+func Serialnumber_listContext(ctx context.Context, provModel ProvModel, key, model string, offset, limit int) ([]SerialNumberEntry, error) {
 	var headers = http.Header{}
-	var data = "offset=" + strconv.Itoa(offset) + "&limit=" + strconv.Itoa(limit)
-	var path = PROVISION_MANAGE_MODEL + model + "/"
-	return ProvCall(path, key, data, "GET", provModel.managebycik, headers)
+	var params = url.Values{}
+	params.Set("offset", strconv.Itoa(offset))
+	params.Set("limit", strconv.Itoa(limit))
+	var path = PROVISION_MANAGE_MODEL + url.PathEscape(model) + "/?" + params.Encode()
+	body, err := provModel.request(ctx, path, key, "", "GET", headers)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSerialNumberList(body)
 }
 
 // serialnumber_reenable implements POST to /provision/manage/model/<MODEL>/<SN>
-func Serialnumber_reenable(provModel ProvModel, key, model, serialnumber string) (interface{}, error) {
+func Serialnumber_reenable(provModel ProvModel, key, model, serialnumber string) error {
+	return Serialnumber_reenableContext(context.Background(), provModel, key, model, serialnumber)
+}
+
+// Serialnumber_reenableContext is like Serialnumber_reenable but gives up when ctx is done
+func Serialnumber_reenableContext(ctx context.Context, provModel ProvModel, key, model, serialnumber string) error {
 	var headers = http.Header{}
-	var data = "enable=true"
-	var path = PROVISION_MANAGE_MODEL + model + "/" + serialnumber
-	return ProvCall(path, key, data, "POST", provModel.managebycik, headers)
+	var params = url.Values{}
+	params.Set("enable", "true")
+	var path = PROVISION_MANAGE_MODEL + url.PathEscape(model) + "/" + url.PathEscape(serialnumber)
+	defer provModel.invalidate(model, serialnumber)
+	_, err := provModel.request(ctx, path, key, params.Encode(), "POST", headers)
+	return err
 }
 
 // serialnumber_remap implements POST to /provision/manage/model/<MODEL>/<SN>
-func Serialnumber_remap(provModel ProvModel, key, model, serialnumber, oldsn string) (interface{}, error) {
+func Serialnumber_remap(provModel ProvModel, key, model, serialnumber, oldsn string) error {
+	return Serialnumber_remapContext(context.Background(), provModel, key, model, serialnumber, oldsn)
+}
+
+// Serialnumber_remapContext is like Serialnumber_remap but gives up when ctx is done
+func Serialnumber_remapContext(ctx context.Context, provModel ProvModel, key, model, serialnumber, oldsn string) error {
 	var headers = http.Header{}
-	var data = "enable=true&oldsn=" + oldsn
-	var path = PROVISION_MANAGE_MODEL + model + "/" + serialnumber
-	return ProvCall(path, key, data, "POST", provModel.managebycik, headers)
+	var params = url.Values{}
+	params.Set("enable", "true")
+	params.Set("oldsn", oldsn)
+	var path = PROVISION_MANAGE_MODEL + url.PathEscape(model) + "/" + url.PathEscape(serialnumber)
+	defer provModel.invalidate(model, serialnumber, oldsn)
+	_, err := provModel.request(ctx, path, key, params.Encode(), "POST", headers)
+	return err
 }
 
 // serialnumber_remove implements DELETE to /provision/manage/model/<MODEL>/<SN>
-func Serialnumber_remove(provModel ProvModel, key, model, serialnumber string) (interface{}, error) {
+func Serialnumber_remove(provModel ProvModel, key, model, serialnumber string) error {
+	return Serialnumber_removeContext(context.Background(), provModel, key, model, serialnumber)
+}
+
+// Serialnumber_removeContext is like Serialnumber_remove but gives up when ctx is done
+func Serialnumber_removeContext(ctx context.Context, provModel ProvModel, key, model, serialnumber string) error {
 	var headers = http.Header{}
-	var path = PROVISION_MANAGE_MODEL + model + "/" + serialnumber
-	return ProvCall(path, key, "", "DELETE", provModel.managebycik, headers)
+	var path = PROVISION_MANAGE_MODEL + url.PathEscape(model) + "/" + url.PathEscape(serialnumber)
+	defer provModel.invalidate(model, serialnumber)
+	_, err := provModel.request(ctx, path, key, "", "DELETE", headers)
+	return err
 }
 
 // serialnumber_remove_batch implements POST to /provision/manage/model/<MODEL>/
-func Serialnumber_remove_batch(provModel ProvModel, key, model string, sns []string) (interface{}, error) {
+func Serialnumber_remove_batch(provModel ProvModel, key, model string, sns []string) error {
+	return Serialnumber_remove_batchContext(context.Background(), provModel, key, model, sns)
+}
+
+// Serialnumber_remove_batchContext is like Serialnumber_remove_batch but gives up when ctx is done
+func Serialnumber_remove_batchContext(ctx context.Context, provModel ProvModel, key, model string, sns []string) error {
 	var headers = http.Header{}
-	var data = "remove=true"
+	var params = url.Values{}
+	params.Set("remove", "true")
 	for i := range sns {
-		data = data + "&sn[]=" + sns[i]
+		params.Add("sn[]", sns[i])
+	}
+	var path = PROVISION_MANAGE_MODEL + url.PathEscape(model) + "/"
+	defer provModel.invalidate(model, sns...)
+	_, err := provModel.request(ctx, path, key, params.Encode(), "POST", headers)
+	return err
+}
+
+// share_create implements POST to /provision/manage/share/, making a share
+// code of the platform usable by the vendor's models
+func Share_create(provModel ProvModel, key, code, meta string) error {
+	return Share_createContext(context.Background(), provModel, key, code, meta)
+}
+
+// Share_createContext is like Share_create but gives up when ctx is done
+func Share_createContext(ctx context.Context, provModel ProvModel, key, code, meta string) error {
+	var headers = http.Header{}
+	var params = url.Values{}
+	params.Set("code", code)
+	params.Set("meta", meta)
+	_, err := provModel.request(ctx, PROVISION_MANAGE_SHARE, key, params.Encode(), "POST", headers)
+	return err
+}
+
+// share_info implements GET to /provision/manage/share/<CODE>
+func Share_info(provModel ProvModel, key, code string) (ShareInfo, error) {
+	return Share_infoContext(context.Background(), provModel, key, code)
+}
+
+// Share_infoContext is like Share_info but gives up when ctx is done
+func Share_infoContext(ctx context.Context, provModel ProvModel, key, code string) (ShareInfo, error) {
+	var headers = http.Header{}
+	body, err := provModel.request(ctx, PROVISION_MANAGE_SHARE+url.PathEscape(code), key, "", "GET", headers)
+	if err != nil {
+		return ShareInfo{}, err
 	}
-	var path = PROVISION_MANAGE_MODEL + model + "/"
-	return ProvCall(path, key, data, "POST", provModel.managebycik, headers)
+	return ParseShareInfo(code, body)
+}
+
+// share_list implements GET to /provision/manage/share/
+func Share_list(provModel ProvModel, key string) ([]string, error) {
+	return Share_listContext(context.Background(), provModel, key)
+}
+
+// Share_listContext is like Share_list but gives up when ctx is done
+func Share_listContext(ctx context.Context, provModel ProvModel, key string) ([]string, error) {
+	var headers = http.Header{}
+	body, err := provModel.request(ctx, PROVISION_MANAGE_SHARE, key, "", "GET", headers)
+	if err != nil {
+		return nil, err
+	}
+	return ParseList(body), nil
+}
+
+// share_remove implements DELETE to /provision/manage/share/<CODE>
+func Share_remove(provModel ProvModel, key, code string) error {
+	return Share_removeContext(context.Background(), provModel, key, code)
+}
+
+// Share_removeContext is like Share_remove but gives up when ctx is done
+func Share_removeContext(ctx context.Context, provModel ProvModel, key, code string) error {
+	var headers = http.Header{}
+	_, err := provModel.request(ctx, PROVISION_MANAGE_SHARE+url.PathEscape(code), key, "", "DELETE", headers)
+	return err
+}
+
+// share_update implements PUT to /provision/manage/share/<CODE>
+func Share_update(provModel ProvModel, key, code, meta string) error {
+	return Share_updateContext(context.Background(), provModel, key, code, meta)
+}
+
+// Share_updateContext is like Share_update but gives up when ctx is done
+func Share_updateContext(ctx context.Context, provModel ProvModel, key, code, meta string) error {
+	var headers = http.Header{}
+	var params = url.Values{}
+	params.Set("meta", meta)
+	_, err := provModel.request(ctx, PROVISION_MANAGE_SHARE+url.PathEscape(code), key, params.Encode(), "PUT", headers)
+	return err
 }
 
 // vendor_register implements POST to /provision/register
-func Vendor_register(provModel ProvModel, key, vendor string) (interface{}, error) {
+func Vendor_register(provModel ProvModel, key, vendor string) error {
+	return Vendor_registerContext(context.Background(), provModel, key, vendor)
+}
+
+// Vendor_registerContext is like Vendor_register but gives up when ctx is done
+func Vendor_registerContext(ctx context.Context, provModel ProvModel, key, vendor string) error {
 	var headers = http.Header{}
-	var data = "vendor=" + vendor
-	return ProvCall(PROVISION_REGISTER, key, data, "POST", provModel.managebycik, headers)
+	var params = url.Values{}
+	params.Set("vendor", vendor)
+	_, err := provModel.request(ctx, PROVISION_REGISTER, key, params.Encode(), "POST", headers)
+	return err
 }
 
 // vendor_show implements GET to /provision/register
-func Vendor_show(key string) (interface{}, error) {
+func Vendor_show(key string) ([]string, error) {
+	return Vendor_showContext(context.Background(), key)
+}
+
+// Vendor_showContext is like Vendor_show but gives up when ctx is done
+func Vendor_showContext(ctx context.Context, key string) ([]string, error) {
 	var headers = http.Header{}
-	return ProvCall(PROVISION_REGISTER, key, "", "GET", false, headers)
+	body, err := provRequest(ctx, PROVISION_REGISTER, key, "", "GET", false, headers)
+	if err != nil {
+		return nil, err
+	}
+	return ParseVendorList(body), nil
 }
 
 // vendor_unregister implements POST to /provision/register
-func Vendor_unregister(key, vendor string) (interface{}, error) {
+func Vendor_unregister(key, vendor string) error {
+	return Vendor_unregisterContext(context.Background(), key, vendor)
+}
+
+// Vendor_unregisterContext is like Vendor_unregister but gives up when ctx is done
+func Vendor_unregisterContext(ctx context.Context, key, vendor string) error {
 	var headers = http.Header{}
-	var data = "delete=true&vendor=" + vendor
-	return ProvCall(PROVISION_REGISTER, key, data, "POST", false, headers)
+	var params = url.Values{}
+	params.Set("delete", "true")
+	params.Set("vendor", vendor)
+	_, err := provRequest(ctx, PROVISION_REGISTER, key, params.Encode(), "POST", false, headers)
+	return err
 }
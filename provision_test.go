@@ -1,9 +1,13 @@
 package goonep
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	// "github.com/stretchr/testify/assert"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/rand"
 	"runtime"
@@ -35,16 +39,36 @@ var portalcik = "PORTALCIKHERE"
 }*/
 
 // errorCheckProvision checks for provisioning API HTTP errors
-func errorCheckProvision(t *testing.T, body string, err interface{}, line int) {
+func errorCheckProvision(t *testing.T, err error, line int) {
 	if err != nil {
-		t.Errorf("Failed: %v", err)
+		t.Errorf("Failed: %v on line %d", err, line+1)
+	}
+}
+
+// setupProvision registers the test vendor with the fake platform and
+// creates the portal and the client models are cloned from
+func setupProvision(t *testing.T) {
+	fake.AddVendor(vendorname, vendortoken)
+	portalcik = genCik()
+	cloneportalcik = portalcik
+
+	resp, err := CreateClient(portalcik, ClientDesc{Name: "clone"})
+	if err != nil {
+		t.Fatalf("Failed to create clone client: %v", err)
 	}
-	if body == "HTTP/1.1 409 Conflict\r\n" || body == "HTTP/1.1 404 Not Found\r\n" || body == "HTTP/1.1 412 Precondition Failed\r\n" {
-		t.Errorf("Failed: %v", "HTTP status error on line "+strconv.Itoa(line+1))
+	resp, err = Info(portalcik, resp.Results[0].Body, map[string]interface{}{"key": true})
+	if err != nil {
+		t.Fatalf("Failed to get clone client key: %v", err)
 	}
+	info, err := resp.Results[0].Info()
+	if err != nil {
+		t.Fatalf("Failed to get clone client key: %v", err)
+	}
+	clonecik = info.Key
 }
 
 func TestMainProvision(t *testing.T) {
+	setupProvision(t)
 	rand.Seed(time.Now().Unix())
 	randomInt := rand.Intn(10000000-0) + 0
 	var model = "MyTestModel" + strconv.Itoa(randomInt)
@@ -56,13 +80,13 @@ func TestMainProvision(t *testing.T) {
 	_, _, line, _ := runtime.Caller(0)
 	errorCheckRPC(t, portalrid, err, line)
 	portalridBody := portalrid.Results[0].Body
-	fmt.Printf("portalrid: " + portalridBody.(string) + "\n\n")
+	fmt.Print("portalrid: " + portalridBody.(string) + "\n\n")
 
 	clonerid, err := Lookup(clonecik, "alias", "")
 	_, _, line, _ = runtime.Caller(0)
 	errorCheckRPC(t, clonerid, err, line)
 	cloneridBody := clonerid.Results[0].Body
-	fmt.Printf("clonerid: " + cloneridBody.(string) + "\n\n")
+	fmt.Print("clonerid: " + cloneridBody.(string) + "\n\n")
 
 	var meta = map[string]interface{}{
 		"meta": "[\"" + vendorname + "\", \"" + model + "\"]",
@@ -77,111 +101,268 @@ func TestMainProvision(t *testing.T) {
 		managebysharecode: true,
 		url:               "https://m2.exosite.com",
 	}
-	body, err := Model_create(provModel, vendortoken, model, sharecodeBody.(string), false, true, true)
-	fmt.Printf(string(body.([]byte)) + "\r\n\r\n")
+	err = Model_create(provModel, vendortoken, model, sharecodeBody.(string), false, true, true)
 	_, _, line, _ = runtime.Caller(0)
-	errorCheckProvision(t, string(body.([]byte)), err, line)
+	errorCheckProvision(t, err, line)
 
-	body, err = Model_list(provModel, vendortoken)
-	fmt.Printf(string(body.([]byte)) + "\r\n\r\n")
+	models, err := Model_list(provModel, vendortoken)
+	fmt.Print(models, "\r\n\r\n")
 	_, _, line, _ = runtime.Caller(0)
-	errorCheckProvision(t, string(body.([]byte)), err, line)
+	errorCheckProvision(t, err, line)
+	if !contains(models, model) {
+		t.Errorf("Failed: %s not in model list %v", model, models)
+	}
 
-	body, err = Model_info(provModel, vendortoken, model)
-	fmt.Printf(string(body.([]byte)) + "\r\n\r\n")
+	modelInfo, err := Model_info(provModel, vendortoken, model)
+	fmt.Print(modelInfo, "\r\n\r\n")
 	_, _, line, _ = runtime.Caller(0)
-	errorCheckProvision(t, string(body.([]byte)), err, line)
+	errorCheckProvision(t, err, line)
+	if modelInfo.Code != sharecodeBody.(string) || modelInfo.Aliases || !modelInfo.Comments || !modelInfo.Historical {
+		t.Errorf("Failed: unexpected model info %+v", modelInfo)
+	}
 
-	body, err = Serialnumber_add(provModel, vendortoken, model, sn1)
-	fmt.Printf(string(body.([]byte)) + "\r\n\r\n")
+	err = Serialnumber_add(provModel, vendortoken, model, sn1)
 	_, _, line, _ = runtime.Caller(0)
-	errorCheckProvision(t, string(body.([]byte)), err, line)
+	errorCheckProvision(t, err, line)
 
 	var sn2andsn3 = []string{sn2, sn3}
-	body, err = Serialnumber_add_batch(provModel, vendortoken, model, sn2andsn3)
-	fmt.Printf(string(body.([]byte)) + "\r\n\r\n")
+	err = Serialnumber_add_batch(provModel, vendortoken, model, sn2andsn3)
 	_, _, line, _ = runtime.Caller(0)
-	errorCheckProvision(t, string(body.([]byte)), err, line)
+	errorCheckProvision(t, err, line)
 
-	body, err = Serialnumber_list(provModel, vendortoken, model, 0, 10)
-	fmt.Printf(string(body.([]byte)) + "\r\n\r\n")
+	sns, err := Serialnumber_list(provModel, vendortoken, model, 0, 10)
+	fmt.Print(sns, "\r\n\r\n")
 	_, _, line, _ = runtime.Caller(0)
-	errorCheckProvision(t, string(body.([]byte)), err, line)
+	errorCheckProvision(t, err, line)
+	if len(sns) != 3 || sns[0].SN != sn1 {
+		t.Errorf("Failed: unexpected serial numbers %v", sns)
+	}
 
-	body, err = Serialnumber_remove_batch(provModel, vendortoken, model, sn2andsn3)
-	fmt.Printf(string(body.([]byte)) + "\r\n\r\n")
+	err = Serialnumber_remove_batch(provModel, vendortoken, model, sn2andsn3)
 	_, _, line, _ = runtime.Caller(0)
-	errorCheckProvision(t, string(body.([]byte)), err, line)
+	errorCheckProvision(t, err, line)
+
+	sns, err = Serialnumber_list(provModel, vendortoken, model, 0, 1000)
+	fmt.Print(sns, "\r\n\r\n")
+	_, _, line, _ = runtime.Caller(0)
+	errorCheckProvision(t, err, line)
+	if len(sns) != 1 {
+		t.Errorf("Failed: unexpected serial numbers %v", sns)
+	}
 
-	body, err = Serialnumber_list(provModel, vendortoken, model, 0, 1000)
-	fmt.Printf(string(body.([]byte)) + "\r\n\r\n")
+	err = Serialnumber_enable(provModel, vendortoken, model, sn1, portalridBody.(string))
 	_, _, line, _ = runtime.Caller(0)
-	errorCheckProvision(t, string(body.([]byte)), err, line)
+	errorCheckProvision(t, err, line)
 
-	body, err = Serialnumber_enable(provModel, vendortoken, model, sn1, portalridBody.(string))
-	fmt.Printf(string(body.([]byte)) + "\r\n\r\n")
+	entry, err := Serialnumber_info(provModel, vendortoken, model, sn1)
+	fmt.Print(entry, "\r\n\r\n")
 	_, _, line, _ = runtime.Caller(0)
-	errorCheckProvision(t, string(body.([]byte)), err, line)
+	errorCheckProvision(t, err, line)
+	if entry.Status != "notactivated" || !validCikRid(entry.Rid) {
+		t.Errorf("Failed: unexpected serial number info %+v", entry)
+	}
 
-	body, err = Serialnumber_info(provModel, vendortoken, model, sn1)
-	fmt.Printf(string(body.([]byte)) + "\r\n\r\n")
+	err = Serialnumber_disable(provModel, vendortoken, model, sn1)
 	_, _, line, _ = runtime.Caller(0)
-	errorCheckProvision(t, string(body.([]byte)), err, line)
+	errorCheckProvision(t, err, line)
 
-	body, err = Serialnumber_disable(provModel, vendortoken, model, sn1)
-	fmt.Printf(string(body.([]byte)) + "\r\n\r\n")
+	entry, err = Serialnumber_info(provModel, vendortoken, model, sn1)
+	fmt.Print(entry, "\r\n\r\n")
 	_, _, line, _ = runtime.Caller(0)
-	errorCheckProvision(t, string(body.([]byte)), err, line)
+	errorCheckProvision(t, err, line)
+	if entry.Status != "disabled" {
+		t.Errorf("Failed: unexpected serial number info %+v", entry)
+	}
 
-	body, err = Serialnumber_info(provModel, vendortoken, model, sn1)
-	fmt.Printf(string(body.([]byte)) + "\r\n\r\n")
+	err = Serialnumber_reenable(provModel, vendortoken, model, sn1)
 	_, _, line, _ = runtime.Caller(0)
-	errorCheckProvision(t, string(body.([]byte)), err, line)
+	errorCheckProvision(t, err, line)
 
-	body, err = Serialnumber_reenable(provModel, vendortoken, model, sn1)
-	fmt.Printf(string(body.([]byte)) + "\r\n\r\n")
+	entry, err = Serialnumber_info(provModel, vendortoken, model, sn1)
+	fmt.Print(entry, "\r\n\r\n")
 	_, _, line, _ = runtime.Caller(0)
-	errorCheckProvision(t, string(body.([]byte)), err, line)
+	errorCheckProvision(t, err, line)
 
-	body, err = Serialnumber_info(provModel, vendortoken, model, sn1)
-	fmt.Printf(string(body.([]byte)) + "\r\n\r\n")
+	devicecik, err := Serialnumber_activate(provModel, model, sn1, vendorname)
+	fmt.Print(devicecik + "\r\n\r\n")
 	_, _, line, _ = runtime.Caller(0)
-	errorCheckProvision(t, string(body.([]byte)), err, line)
+	errorCheckProvision(t, err, line)
+	if !validCikRid(devicecik) {
+		t.Errorf("Failed: activation returned %q", devicecik)
+	}
 
-	body, err = Serialnumber_activate(provModel, model, sn1, vendorname)
-	fmt.Printf(string(body.([]byte)) + "\r\n\r\n")
+	entry, err = Serialnumber_info(provModel, vendortoken, model, sn1)
+	fmt.Print(entry, "\r\n\r\n")
 	_, _, line, _ = runtime.Caller(0)
-	errorCheckProvision(t, string(body.([]byte)), err, line)
+	errorCheckProvision(t, err, line)
+	if entry.Status != "activated" {
+		t.Errorf("Failed: unexpected serial number info %+v", entry)
+	}
 
-	body, err = Serialnumber_info(provModel, vendortoken, model, sn1)
-	fmt.Printf(string(body.([]byte)) + "\r\n\r\n")
+	err = Content_create(provModel, vendortoken, model, "a.txt", "This is text", false)
 	_, _, line, _ = runtime.Caller(0)
-	errorCheckProvision(t, string(body.([]byte)), err, line)
+	errorCheckProvision(t, err, line)
 
-	body, err = Content_create(provModel, vendortoken, model, "a.txt", "This is text", false)
-	fmt.Printf(string(body.([]byte)) + "\r\n\r\n")
+	err = Content_upload(provModel, vendortoken, model, "a.txt", "This is content data", "text/plain")
 	_, _, line, _ = runtime.Caller(0)
-	errorCheckProvision(t, string(body.([]byte)), err, line)
+	errorCheckProvision(t, err, line)
 
-	body, err = Content_upload(provModel, vendortoken, model, "a.txt", "This is content data", "text/plain")
-	fmt.Printf(string(body.([]byte)) + "\r\n\r\n")
+	contents, err := Content_list(provModel, vendortoken, model)
+	fmt.Print(contents, "\r\n\r\n")
 	_, _, line, _ = runtime.Caller(0)
-	errorCheckProvision(t, string(body.([]byte)), err, line)
+	errorCheckProvision(t, err, line)
+	if len(contents) != 1 || contents[0].ID != "a.txt" || contents[0].Size != 20 || contents[0].MIME != "text/plain" {
+		t.Errorf("Failed: unexpected content list %+v", contents)
+	}
 
-	body, err = Content_list(provModel, vendortoken, model)
-	fmt.Printf(string(body.([]byte)) + "\r\n\r\n")
+	data, err := Content_download(provModel, devicecik, vendorname, model, "a.txt")
 	_, _, line, _ = runtime.Caller(0)
-	errorCheckProvision(t, string(body.([]byte)), err, line)
+	errorCheckProvision(t, err, line)
+	if string(data) != "This is content data" {
+		t.Errorf("Failed: downloaded %q", data)
+	}
+
+	// the device CIK is sent as a CIK however the model is managed, and an
+	// empty one does not fall back to the vendor token
+	tokenModel := NewProvisioningClient(AuthVendorToken, vendortoken).Model()
+	if data, err := Content_download(tokenModel, devicecik, vendorname, model, "a.txt"); err != nil || string(data) != "This is content data" {
+		t.Errorf("Failed: downloaded %q: %v", data, err)
+	}
+	if info, err := Content_info(tokenModel, devicecik, model, "a.txt", vendorname); err != nil || info.Size != 20 {
+		t.Errorf("Failed: unexpected content info %+v %v", info, err)
+	}
+	if _, err := Content_download(tokenModel, "", vendorname, model, "a.txt"); !IsUnauthorized(err) {
+		t.Errorf("Failed: expected unauthorized, got %v", err)
+	}
 
-	body, err = Content_remove(provModel, vendortoken, model, "a.txt")
-	fmt.Printf(string(body.([]byte)) + "\r\n\r\n")
+	err = Content_remove(provModel, vendortoken, model, "a.txt")
 	_, _, line, _ = runtime.Caller(0)
-	errorCheckProvision(t, string(body.([]byte)), err, line)
+	errorCheckProvision(t, err, line)
 
-	body, err = Model_remove(provModel, vendortoken, model)
-	fmt.Printf(string(body.([]byte)) + "\r\n\r\n")
+	err = Model_remove(provModel, vendortoken, model)
 	_, _, line, _ = runtime.Caller(0)
-	errorCheckProvision(t, string(body.([]byte)), err, line)
+	errorCheckProvision(t, err, line)
+}
+
+func TestProvisionErrors(t *testing.T) {
+	setupProvision(t)
+	provModel := ProvModel{}
+	var model = "MyErrorModel" + strconv.Itoa(rand.Intn(10000000))
+
+	_, err := Model_info(provModel, vendortoken, model)
+	if !IsNotFound(err) || IsConflict(err) {
+		t.Errorf("Failed: expected not found, got %v", err)
+	}
+	var provErr *ProvisionError
+	if !errors.As(err, &provErr) || provErr.Path != PROVISION_MANAGE_MODEL+model || provErr.Body != "HTTP/1.1 404 Not Found\r\n" {
+		t.Errorf("Failed: unexpected error %#v", err)
+	}
+
+	err = Model_create(provModel, vendortoken, model, "0000000000000000000000000000000000000000", true, true, true)
+	if !IsPreconditionFailed(err) {
+		t.Errorf("Failed: expected precondition failed, got %v", err)
+	}
+
+	resp, _ := Lookup(clonecik, "alias", "")
+	err = Model_create(provModel, vendortoken, model, resp.Results[0].Body.(string), true, true, true)
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	err = Serialnumber_add(provModel, vendortoken, model, "001")
+	if err != nil {
+		t.Errorf("Failed: %v", err)
+	}
+	err = Serialnumber_add(provModel, vendortoken, model, "001")
+	if !IsConflict(err) {
+		t.Errorf("Failed: expected conflict, got %v", err)
+	}
+
+	_, err = Model_list(provModel, "badtoken")
+	if !IsUnauthorized(err) || !errors.Is(err, ErrNoAuth) {
+		t.Errorf("Failed: expected unauthorized, got %v", err)
+	}
+
+	if err := Model_remove(provModel, vendortoken, model); err != nil {
+		t.Errorf("Failed: %v", err)
+	}
+}
+
+func TestProvisionEncoding(t *testing.T) {
+	setupProvision(t)
+	provModel := ProvModel{}
+	var model = "My Encoded&Model=" + strconv.Itoa(rand.Intn(10000000))
+
+	resp, _ := Lookup(clonecik, "alias", "")
+	if err := Model_create(provModel, vendortoken, model, resp.Results[0].Body.(string), false, false, true); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	info, err := Model_info(provModel, vendortoken, model)
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if info.Aliases || info.Comments || !info.Historical {
+		t.Errorf("Failed: options were not sent as options[] %+v", info)
+	}
+
+	var sns = []string{"sn 1&x=y", "sn+2"}
+	if err := Serialnumber_add_batch(provModel, vendortoken, model, sns); err != nil {
+		t.Errorf("Failed: %v", err)
+	}
+	entries, err := Serialnumber_list(provModel, vendortoken, model, 1, 10)
+	if err != nil {
+		t.Errorf("Failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].SN != sns[1] {
+		t.Errorf("Failed: unexpected serial numbers %+v", entries)
+	}
+
+	var meta = "a&b=c d, e"
+	if err := Content_create(provModel, vendortoken, model, "a.txt", meta, true); err != nil {
+		t.Errorf("Failed: %v", err)
+	}
+	if err := Content_upload(provModel, vendortoken, model, "a.txt", "x=1&y=2", "text/plain"); err != nil {
+		t.Errorf("Failed: %v", err)
+	}
+	content, err := Content_info(provModel, vendortoken, model, "a.txt", "")
+	if err != nil {
+		t.Errorf("Failed: %v", err)
+	}
+	if content.Meta != meta || !content.Protected || content.MIME != "text/plain" || content.Size != 7 {
+		t.Errorf("Failed: unexpected content info %+v", content)
+	}
+
+	if err := Model_remove(provModel, vendortoken, model); err != nil {
+		t.Errorf("Failed: %v", err)
+	}
+}
+
+func TestProvisionFormContentType(t *testing.T) {
+	var gotMethod, gotType, gotMeta string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod = r.Method
+		gotType = r.Header.Get("Content-Type")
+		r.ParseForm()
+		gotMeta = r.PostForm.Get("meta")
+	}))
+	defer server.Close()
+
+	client := &Client{Scheme: "http", Host: strings.TrimPrefix(server.URL, "http://")}
+	if err := Content_update(ProvModel{client: client}, vendortoken, "model", "a.txt", "a&b", false); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if gotMethod != "PUT" || !strings.HasPrefix(gotType, "application/x-www-form-urlencoded") || gotMeta != "a&b" {
+		t.Errorf("Failed: %s sent with content type %q and meta %q", gotMethod, gotType, gotMeta)
+	}
+}
+
+// contains reports whether list holds s
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
 }
 
 func dump(o interface{}) {
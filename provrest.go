@@ -0,0 +1,264 @@
+package goonep
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+// ProvRestModel is the create, read, update and delete interface shared by
+// the resources of the provisioning API. T is the typed description of one
+// resource, e.g. ModelInfo for models. Key is the vendor token the request
+// is made with.
+type ProvRestModel[T any] interface {
+
+	// GetPath retrive the URL path for each different models
+	GetPath() string
+
+	Create(ctx context.Context, key string, attr T) error
+
+	Find(ctx context.Context, key, id string) (T, error)
+	All(ctx context.Context, key string) ([]T, error)
+
+	Update(ctx context.Context, key string, attr T) error
+	Delete(ctx context.Context, key, id string) error
+}
+
+var (
+	_ ProvRestModel[ModelInfo]   = &ProvModels{}
+	_ ProvRestModel[ContentInfo] = &ProvContent{}
+	_ ProvRestModel[GroupInfo]   = &ProvGroup{}
+	_ ProvRestModel[ShareInfo]   = &ProvShare{}
+)
+
+// ProvModels manages the models of a vendor. It is the ProvModel it embeds
+// with Find looking up models rather than serial numbers.
+type ProvModels struct {
+	ProvModel
+}
+
+// ProvContent manages the content of one model. Get one for a model from
+// NewProvContent or ForModel:
+//
+//	content := goonep.NewProvContent("mymodel")
+//	items, err := content.All(ctx, vendortoken)
+type ProvContent struct {
+	Model string
+
+	prov ProvModel
+}
+
+// ProvGroup manages groups of devices
+type ProvGroup struct {
+	prov ProvModel
+}
+
+// ProvShare manages the share codes available to a vendor's models
+type ProvShare struct {
+	prov ProvModel
+}
+
+var Provision struct {
+	// Manage has no Content, which is per model: see NewProvContent
+	Manage struct {
+		Group  ProvGroup
+		Model  ProvModel
+		Models ProvModels
+		Share  ProvShare
+	}
+
+	Admin struct {
+		Auth ProvModel
+	}
+
+	Register ProvModel
+}
+
+var errNoModel = errors.New("goonep: ProvContent has no Model, get one from NewProvContent or ForModel")
+
+// NewProvContent returns the content of model, managed by vendor token on
+// the default client
+func NewProvContent(model string) *ProvContent {
+	return &ProvContent{Model: model}
+}
+
+// ForModel returns the content of model, managed the same way as c
+func (c *ProvContent) ForModel(model string) *ProvContent {
+	return &ProvContent{Model: model, prov: c.prov}
+}
+
+func (m *ProvModel) GetPath() string {
+	return PROVISION_MANAGE_MODEL
+}
+
+// Create creates the model info.Name. Aliases, Comments and Historical are
+// sent as they are, so leaving them false turns copying off.
+func (m *ProvModel) Create(ctx context.Context, key string, info ModelInfo) error {
+	params := info.Spec().values()
+	params.Set("model", info.Name)
+	_, err := m.request(ctx, PROVISION_MANAGE_MODEL, key, params.Encode(), "POST", http.Header{})
+	return err
+}
+
+// Find returns the model named id
+func (m *ProvModels) Find(ctx context.Context, key, id string) (ModelInfo, error) {
+	return Model_infoContext(ctx, m.ProvModel, key, id)
+}
+
+// All returns every model of the vendor, asking for each one's info in
+// turn
+func (m *ProvModel) All(ctx context.Context, key string) ([]ModelInfo, error) {
+	names, err := Model_listContext(ctx, *m, key)
+	if err != nil {
+		return nil, err
+	}
+	var models []ModelInfo
+	for _, name := range names {
+		info, err := Model_infoContext(ctx, *m, key, name)
+		if err != nil {
+			return nil, err
+		}
+		models = append(models, info)
+	}
+	return models, nil
+}
+
+// Update changes the clone source and options of model info.Name
+func (m *ProvModel) Update(ctx context.Context, key string, info ModelInfo) error {
+	var path = PROVISION_MANAGE_MODEL + url.PathEscape(info.Name)
+	_, err := m.request(ctx, path, key, info.Spec().values().Encode(), "PUT", http.Header{})
+	return err
+}
+
+// Delete removes the model named id along with its serial numbers
+func (m *ProvModel) Delete(ctx context.Context, key, id string) error {
+	return Model_removeContext(ctx, *m, key, id)
+}
+
+func (c *ProvContent) GetPath() string {
+	return PROVISION_MANAGE_CONTENT + url.PathEscape(c.Model) + "/"
+}
+
+// Create creates the content item info.ID. Only its Meta and Protected
+// are used; upload data with Content_upload.
+func (c *ProvContent) Create(ctx context.Context, key string, info ContentInfo) error {
+	if c.Model == "" {
+		return errNoModel
+	}
+	return Content_createContext(ctx, c.prov, key, c.Model, info.ID, info.Meta, info.Protected)
+}
+
+// Find returns the content item id
+func (c *ProvContent) Find(ctx context.Context, key, id string) (ContentInfo, error) {
+	if c.Model == "" {
+		return ContentInfo{}, errNoModel
+	}
+	return Content_infoContext(ctx, c.prov, key, c.Model, id, "")
+}
+
+// All returns every content item of the model
+func (c *ProvContent) All(ctx context.Context, key string) ([]ContentInfo, error) {
+	if c.Model == "" {
+		return nil, errNoModel
+	}
+	return Content_listContext(ctx, c.prov, key, c.Model)
+}
+
+// Update changes the Meta and Protected flag of content item info.ID
+func (c *ProvContent) Update(ctx context.Context, key string, info ContentInfo) error {
+	if c.Model == "" {
+		return errNoModel
+	}
+	return Content_updateContext(ctx, c.prov, key, c.Model, info.ID, info.Meta, info.Protected)
+}
+
+// Delete removes the content item id
+func (c *ProvContent) Delete(ctx context.Context, key, id string) error {
+	if c.Model == "" {
+		return errNoModel
+	}
+	return Content_removeContext(ctx, c.prov, key, c.Model, id)
+}
+
+func (g *ProvGroup) GetPath() string {
+	return PROVISION_MANAGE_GROUP
+}
+
+// Create creates the group info.Name
+func (g *ProvGroup) Create(ctx context.Context, key string, info GroupInfo) error {
+	return Group_createContext(ctx, g.prov, key, info.Name, info.Meta, info.Members)
+}
+
+// Find returns the group named id
+func (g *ProvGroup) Find(ctx context.Context, key, id string) (GroupInfo, error) {
+	return Group_infoContext(ctx, g.prov, key, id)
+}
+
+// All returns every group of the vendor
+func (g *ProvGroup) All(ctx context.Context, key string) ([]GroupInfo, error) {
+	names, err := Group_listContext(ctx, g.prov, key)
+	if err != nil {
+		return nil, err
+	}
+	var groups []GroupInfo
+	for _, name := range names {
+		info, err := Group_infoContext(ctx, g.prov, key, name)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, info)
+	}
+	return groups, nil
+}
+
+// Update replaces the meta and members of group info.Name
+func (g *ProvGroup) Update(ctx context.Context, key string, info GroupInfo) error {
+	return Group_updateContext(ctx, g.prov, key, info.Name, info.Meta, info.Members)
+}
+
+// Delete removes the group named id
+func (g *ProvGroup) Delete(ctx context.Context, key, id string) error {
+	return Group_removeContext(ctx, g.prov, key, id)
+}
+
+func (s *ProvShare) GetPath() string {
+	return PROVISION_MANAGE_SHARE
+}
+
+// Create registers the share code info.Code
+func (s *ProvShare) Create(ctx context.Context, key string, info ShareInfo) error {
+	return Share_createContext(ctx, s.prov, key, info.Code, info.Meta)
+}
+
+// Find returns the share code id
+func (s *ProvShare) Find(ctx context.Context, key, id string) (ShareInfo, error) {
+	return Share_infoContext(ctx, s.prov, key, id)
+}
+
+// All returns every share code registered by the vendor
+func (s *ProvShare) All(ctx context.Context, key string) ([]ShareInfo, error) {
+	codes, err := Share_listContext(ctx, s.prov, key)
+	if err != nil {
+		return nil, err
+	}
+	var shares []ShareInfo
+	for _, code := range codes {
+		info, err := Share_infoContext(ctx, s.prov, key, code)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, info)
+	}
+	return shares, nil
+}
+
+// Update changes the meta of share code info.Code
+func (s *ProvShare) Update(ctx context.Context, key string, info ShareInfo) error {
+	return Share_updateContext(ctx, s.prov, key, info.Code, info.Meta)
+}
+
+// Delete unregisters the share code id
+func (s *ProvShare) Delete(ctx context.Context, key, id string) error {
+	return Share_removeContext(ctx, s.prov, key, id)
+}
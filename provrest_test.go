@@ -0,0 +1,116 @@
+package goonep
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func TestProvRestModel(t *testing.T) {
+	setupProvision(t)
+	ctx := context.Background()
+	var name = "MyRestModel" + strconv.Itoa(rand.Intn(10000000))
+
+	resp, _ := Lookup(clonecik, "alias", "")
+	clonerid := resp.Results[0].Body.(string)
+
+	models := &Provision.Manage.Models
+	err := models.Create(ctx, vendortoken, ModelInfo{Name: name, Rid: clonerid, Comments: true})
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	model, err := models.Find(ctx, vendortoken, name)
+	if err != nil {
+		t.Errorf("Failed: %v", err)
+	}
+	if model.Rid != clonerid || model.Aliases || !model.Comments || model.Historical {
+		t.Errorf("Failed: unexpected model %+v", model)
+	}
+	model.Historical = true
+	if err := models.Update(ctx, vendortoken, model); err != nil {
+		t.Errorf("Failed: %v", err)
+	}
+	all, err := models.All(ctx, vendortoken)
+	if err != nil {
+		t.Errorf("Failed: %v", err)
+	}
+	var found bool
+	for _, m := range all {
+		found = found || m.Name == name && m.Historical
+	}
+	if !found {
+		t.Errorf("Failed: %s missing from models %+v", name, all)
+	}
+
+	content := NewProvContent(name)
+	if err := content.Create(ctx, vendortoken, ContentInfo{ID: "a.txt", Meta: "first"}); err != nil {
+		t.Errorf("Failed: %v", err)
+	}
+	if err := content.Update(ctx, vendortoken, ContentInfo{ID: "a.txt", Meta: "second", Protected: true}); err != nil {
+		t.Errorf("Failed: %v", err)
+	}
+	item, err := content.Find(ctx, vendortoken, "a.txt")
+	if err != nil || item.Meta != "second" || !item.Protected {
+		t.Errorf("Failed: unexpected content %+v %v", item, err)
+	}
+	if err := content.Delete(ctx, vendortoken, "a.txt"); err != nil {
+		t.Errorf("Failed: %v", err)
+	}
+	if items, err := content.All(ctx, vendortoken); err != nil || len(items) != 0 {
+		t.Errorf("Failed: unexpected content %+v %v", items, err)
+	}
+	if _, err := (&ProvContent{}).All(ctx, vendortoken); err != errNoModel {
+		t.Errorf("Failed: expected errNoModel, got %v", err)
+	}
+
+	groups := &Provision.Manage.Group
+	if err := groups.Create(ctx, vendortoken, GroupInfo{Name: "g", Meta: "m", Members: []string{"a", "b"}}); err != nil {
+		t.Errorf("Failed: %v", err)
+	}
+	if err := groups.Create(ctx, vendortoken, GroupInfo{Name: "g"}); !IsConflict(err) {
+		t.Errorf("Failed: expected conflict, got %v", err)
+	}
+	if err := groups.Update(ctx, vendortoken, GroupInfo{Name: "g", Meta: "n", Members: []string{"c"}}); err != nil {
+		t.Errorf("Failed: %v", err)
+	}
+	if all, err := groups.All(ctx, vendortoken); err != nil || len(all) != 1 || all[0].Meta != "n" || len(all[0].Members) != 1 {
+		t.Errorf("Failed: unexpected groups %+v %v", all, err)
+	}
+	if err := groups.Delete(ctx, vendortoken, "g"); err != nil {
+		t.Errorf("Failed: %v", err)
+	}
+	if _, err := groups.Find(ctx, vendortoken, "g"); !IsNotFound(err) {
+		t.Errorf("Failed: expected not found, got %v", err)
+	}
+
+	resp, err = Share(portalcik, clonerid, map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	code := resp.Results[0].Body.(string)
+	shares := &Provision.Manage.Share
+	if err := shares.Create(ctx, vendortoken, ShareInfo{Code: code, Meta: "clone source"}); err != nil {
+		t.Errorf("Failed: %v", err)
+	}
+	if err := shares.Create(ctx, vendortoken, ShareInfo{Code: "unknown"}); !IsPreconditionFailed(err) {
+		t.Errorf("Failed: expected precondition failed, got %v", err)
+	}
+	if err := shares.Update(ctx, vendortoken, ShareInfo{Code: code, Meta: "renamed"}); err != nil {
+		t.Errorf("Failed: %v", err)
+	}
+	share, err := shares.Find(ctx, vendortoken, code)
+	if err != nil || share.Rid != clonerid || share.Meta != "renamed" {
+		t.Errorf("Failed: unexpected share %+v %v", share, err)
+	}
+	if err := shares.Delete(ctx, vendortoken, code); err != nil {
+		t.Errorf("Failed: %v", err)
+	}
+	if all, err := shares.All(ctx, vendortoken); err != nil || len(all) != 0 {
+		t.Errorf("Failed: unexpected shares %+v %v", all, err)
+	}
+
+	if err := models.Delete(ctx, vendortoken, name); err != nil {
+		t.Errorf("Failed: %v", err)
+	}
+}
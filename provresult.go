@@ -0,0 +1,216 @@
+package goonep
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ModelInfo is a client model as returned by Model_info
+type ModelInfo struct {
+	Name string
+
+	// Rid is the client new devices are cloned from. Code is the share
+	// code it was given by, when the model is managed by share code.
+	Rid  string
+	Code string
+
+	// Aliases, Comments and Historical tell whether aliases, comments and
+	// data points are copied from the clone source
+	Aliases    bool
+	Comments   bool
+	Historical bool
+
+	// Options are the raw options[] values, e.g. "noaliases"
+	Options []string
+}
+
+// SerialNumberEntry is a serial number of a model. Status is only known
+// from Serialnumber_info and is one of "unused", "notactivated",
+// "activated" or "disabled".
+type SerialNumberEntry struct {
+	SN     string
+	Rid    string
+	Status string
+	Extra  string
+}
+
+// ContentInfo describes a content item of a model
+type ContentInfo struct {
+	ID        string
+	MIME      string
+	Size      int64
+	Updated   time.Time
+	Meta      string
+	Protected bool
+}
+
+// GroupInfo is a group of devices as returned by Group_info
+type GroupInfo struct {
+	Name    string
+	Meta    string
+	Members []string
+}
+
+// ShareInfo is a share code registered with the provisioning API, as
+// returned by Share_info. Rid is the shared resource.
+type ShareInfo struct {
+	Code string
+	Rid  string
+	Meta string
+}
+
+// lines splits a text/plain or text/csv response into its non-empty lines
+func lines(body []byte) []string {
+	var result []string
+	for _, line := range strings.Split(string(body), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line != "" {
+			result = append(result, line)
+		}
+	}
+	return result
+}
+
+// unquote removes the quotes around a csv field
+func unquote(field string) string {
+	if len(field) >= 2 && field[0] == '"' && field[len(field)-1] == '"' {
+		return strings.Replace(field[1:len(field)-1], `""`, `"`, -1)
+	}
+	return field
+}
+
+// ParseModelInfo parses the url encoded body of a model info response
+func ParseModelInfo(name string, body []byte) (ModelInfo, error) {
+	values, err := url.ParseQuery(strings.TrimSpace(string(body)))
+	if err != nil {
+		return ModelInfo{}, fmt.Errorf("goonep: unexpected model info %q", body)
+	}
+	info := ModelInfo{
+		Name:       name,
+		Rid:        values.Get("rid"),
+		Code:       values.Get("code"),
+		Aliases:    true,
+		Comments:   true,
+		Historical: true,
+		Options:    values["options[]"],
+	}
+	for _, option := range info.Options {
+		switch option {
+		case "noaliases":
+			info.Aliases = false
+		case "nocomments":
+			info.Comments = false
+		case "nohistorical":
+			info.Historical = false
+		}
+	}
+	return info, nil
+}
+
+// ParseGroupInfo parses the url encoded body of a group info response
+func ParseGroupInfo(name string, body []byte) (GroupInfo, error) {
+	values, err := url.ParseQuery(strings.TrimSpace(string(body)))
+	if err != nil {
+		return GroupInfo{}, fmt.Errorf("goonep: unexpected group info %q", body)
+	}
+	return GroupInfo{Name: name, Meta: values.Get("meta"), Members: values["members[]"]}, nil
+}
+
+// ParseShareInfo parses the url encoded body of a share info response
+func ParseShareInfo(code string, body []byte) (ShareInfo, error) {
+	values, err := url.ParseQuery(strings.TrimSpace(string(body)))
+	if err != nil {
+		return ShareInfo{}, fmt.Errorf("goonep: unexpected share info %q", body)
+	}
+	return ShareInfo{Code: code, Rid: values.Get("rid"), Meta: values.Get("meta")}, nil
+}
+
+// ParseSerialNumberList parses the "sn,rid,extra" lines of a serial number
+// listing. Extra may contain commas.
+func ParseSerialNumberList(body []byte) ([]SerialNumberEntry, error) {
+	var entries []SerialNumberEntry
+	for _, line := range lines(body) {
+		fields := strings.SplitN(line, ",", 3)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("goonep: unexpected serial number entry %q", line)
+		}
+		entry := SerialNumberEntry{SN: fields[0], Rid: fields[1]}
+		if len(fields) == 3 {
+			entry.Extra = unquote(fields[2])
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// ParseSerialNumberInfo parses the "status,rid,extra" body of a serial
+// number info response
+func ParseSerialNumberInfo(sn string, body []byte) (SerialNumberEntry, error) {
+	text := strings.TrimSpace(string(body))
+	fields := strings.SplitN(text, ",", 3)
+	if len(fields) < 2 {
+		return SerialNumberEntry{}, fmt.Errorf("goonep: unexpected serial number info %q", text)
+	}
+	entry := SerialNumberEntry{SN: sn, Status: fields[0], Rid: fields[1]}
+	if len(fields) == 3 {
+		entry.Extra = unquote(fields[2])
+	}
+	return entry, nil
+}
+
+// ParseContentInfo parses the "mime,size,updated,meta,protected" body of
+// a content info response. Meta may contain commas.
+func ParseContentInfo(id string, body []byte) (ContentInfo, error) {
+	text := strings.TrimSpace(string(body))
+	fields := strings.Split(text, ",")
+	if len(fields) < 4 {
+		return ContentInfo{}, fmt.Errorf("goonep: unexpected content info %q", text)
+	}
+	info := ContentInfo{ID: id, MIME: fields[0]}
+
+	size, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return ContentInfo{}, fmt.Errorf("goonep: unexpected content size %q", fields[1])
+	}
+	info.Size = size
+
+	updated, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return ContentInfo{}, fmt.Errorf("goonep: unexpected content timestamp %q", fields[2])
+	}
+	info.Updated = time.Unix(updated, 0)
+
+	meta := fields[3:]
+	if len(fields) > 4 {
+		if protected, err := strconv.ParseBool(fields[len(fields)-1]); err == nil {
+			info.Protected = protected
+			meta = fields[3 : len(fields)-1]
+		}
+	}
+	info.Meta = unquote(strings.Join(meta, ","))
+	return info, nil
+}
+
+// ParseList parses a response listing one name per line, such as a model,
+// group, share or content listing
+func ParseList(body []byte) []string {
+	return lines(body)
+}
+
+// ParseVendorList parses a vendor listing, which names one vendor per line
+// either bare or as "vendor=<name>"
+func ParseVendorList(body []byte) []string {
+	var vendors []string
+	for _, line := range lines(body) {
+		if strings.HasPrefix(line, "vendor=") {
+			if values, err := url.ParseQuery(line); err == nil {
+				line = values.Get("vendor")
+			}
+		}
+		vendors = append(vendors, line)
+	}
+	return vendors
+}
@@ -0,0 +1,52 @@
+package goonep
+
+import (
+	"testing"
+)
+
+func TestProvisionParsers(t *testing.T) {
+	info, err := ParseModelInfo("m", []byte("code=abc&options%5B%5D=noaliases&options%5B%5D=nohistorical&rid=0123\r\n"))
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if info.Name != "m" || info.Code != "abc" || info.Rid != "0123" || info.Aliases || !info.Comments || info.Historical {
+		t.Errorf("Unexpected model info: %+v", info)
+	}
+
+	entries, err := ParseSerialNumberList([]byte("001,0123,\r\n002,,{\"a\":1,\"b\":2}\r\n003,4567,\"quoted \"\"extra\"\"\""))
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if len(entries) != 3 || entries[0].SN != "001" || entries[0].Rid != "0123" || entries[1].Extra != `{"a":1,"b":2}` || entries[2].Extra != `quoted "extra"` {
+		t.Errorf("Unexpected serial numbers: %+v", entries)
+	}
+	if _, err := ParseSerialNumberList([]byte("garbage")); err == nil {
+		t.Errorf("Failed: expected an error for a malformed listing")
+	}
+
+	entry, err := ParseSerialNumberInfo("001", []byte("activated,0123,extra,with,commas\r\n"))
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if entry.SN != "001" || entry.Status != "activated" || entry.Rid != "0123" || entry.Extra != "extra,with,commas" {
+		t.Errorf("Unexpected serial number info: %+v", entry)
+	}
+
+	content, err := ParseContentInfo("a.txt", []byte("text/plain,20,1400000000,some, meta,true\r\n"))
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if content.ID != "a.txt" || content.MIME != "text/plain" || content.Size != 20 || content.Updated.Unix() != 1400000000 || content.Meta != "some, meta" || !content.Protected {
+		t.Errorf("Unexpected content info: %+v", content)
+	}
+	if _, err := ParseContentInfo("a.txt", []byte("text/plain,big,1400000000,meta")); err == nil {
+		t.Errorf("Failed: expected an error for a malformed size")
+	}
+
+	if vendors := ParseVendorList([]byte("vendor=acme%20corp\r\nother\r\n")); len(vendors) != 2 || vendors[0] != "acme corp" || vendors[1] != "other" {
+		t.Errorf("Unexpected vendors: %v", vendors)
+	}
+	if names := ParseList([]byte("")); len(names) != 0 {
+		t.Errorf("Unexpected names: %v", names)
+	}
+}
@@ -0,0 +1,160 @@
+package goonep
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Point is one data point of a dataport. Value holds a json.Number for
+// numeric dataports and a string otherwise.
+type Point struct {
+	Timestamp int64
+	Value     interface{}
+}
+
+// ResourceInfo is the result of an info call. Only the parts asked for in
+// the call's options are filled in.
+type ResourceInfo struct {
+	Basic struct {
+		Created     int64  `json:"created,omitempty"`
+		Modified    int64  `json:"modified,omitempty"`
+		Status      string `json:"status,omitempty"`
+		Subscribers int    `json:"subscribers,omitempty"`
+		Type        string `json:"type,omitempty"`
+	} `json:"basic,omitempty"`
+
+	// Aliases maps the RIDs of a client's resources to their aliases
+	Aliases AliasMap `json:"aliases,omitempty"`
+
+	// Comments are [visibility, text] pairs
+	Comments [][]string `json:"comments,omitempty"`
+
+	Counts map[string]int `json:"counts,omitempty"`
+
+	// Description depends on the type of the resource
+	Description map[string]interface{} `json:"description,omitempty"`
+
+	Key    string        `json:"key,omitempty"`
+	Shares []interface{} `json:"shares,omitempty"`
+
+	Storage struct {
+		Count int   `json:"count,omitempty"`
+		First int64 `json:"first,omitempty"`
+		Last  int64 `json:"last,omitempty"`
+		Size  int   `json:"size,omitempty"`
+	} `json:"storage,omitempty"`
+
+	Subscribers []interface{}    `json:"subscribers,omitempty"`
+	Tags        []string         `json:"tags,omitempty"`
+	Usage       map[string]int64 `json:"usage,omitempty"`
+}
+
+// AliasMap maps RIDs to the aliases they are mapped to
+type AliasMap map[string][]string
+
+// UnmarshalJSON accepts the empty list the server sends for resources that
+// cannot have aliases
+func (a *AliasMap) UnmarshalJSON(data []byte) error {
+	var list []interface{}
+	if json.Unmarshal(data, &list) == nil {
+		*a = AliasMap{}
+		return nil
+	}
+	var m map[string][]string
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	*a = AliasMap(m)
+	return nil
+}
+
+// decodeBody converts the body of a successful result into v
+func (r Result) decodeBody(procedure string, v interface{}) error {
+	if err := r.Err(); err != nil {
+		return err
+	}
+	buf, err := json.Marshal(r.Body)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(buf, v); err != nil {
+		return fmt.Errorf("goonep: unexpected %s result %s: %v", procedure, buf, err)
+	}
+	return nil
+}
+
+// Points returns the data points of a read result
+func (r Result) Points() ([]Point, error) {
+	var raw [][]json.RawMessage
+	if err := r.decodeBody("read", &raw); err != nil {
+		return nil, err
+	}
+
+	points := make([]Point, 0, len(raw))
+	for _, entry := range raw {
+		if len(entry) != 2 {
+			return nil, fmt.Errorf("goonep: unexpected read entry with %d fields", len(entry))
+		}
+		var point Point
+		if err := json.Unmarshal(entry[0], &point.Timestamp); err != nil {
+			return nil, fmt.Errorf("goonep: unexpected read timestamp %s", entry[0])
+		}
+		d := json.NewDecoder(bytes.NewReader(entry[1]))
+		d.UseNumber()
+		if err := d.Decode(&point.Value); err != nil {
+			return nil, err
+		}
+		points = append(points, point)
+	}
+	return points, nil
+}
+
+// Info returns the resource information of an info result
+func (r Result) Info() (*ResourceInfo, error) {
+	info := &ResourceInfo{}
+	if err := r.decodeBody("info", info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Listing returns the RIDs of a listing result by resource type. The
+// server keys its answer by type when listing was called with options, see
+// ListingWithOptions. Otherwise it answers with a list of RIDs per type
+// requested, and types must be the types listing was called with.
+func (r Result) Listing(types ...string) (map[string][]string, error) {
+	var raw json.RawMessage
+	if err := r.decodeBody("listing", &raw); err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(bytes.TrimSpace(raw), []byte("[")) {
+		var listing map[string][]string
+		if err := json.Unmarshal(raw, &listing); err != nil {
+			return nil, fmt.Errorf("goonep: unexpected listing result %s: %v", raw, err)
+		}
+		return listing, nil
+	}
+
+	var positional [][]string
+	if err := json.Unmarshal(raw, &positional); err != nil {
+		return nil, fmt.Errorf("goonep: unexpected listing result %s: %v", raw, err)
+	}
+	if len(positional) != len(types) {
+		return nil, fmt.Errorf("goonep: listing result has %d lists of RIDs, types name %d", len(positional), len(types))
+	}
+	listing := make(map[string][]string, len(types))
+	for i, typ := range types {
+		listing[typ] = positional[i]
+	}
+	return listing, nil
+}
+
+// RID returns the resource id of a create or lookup result
+func (r Result) RID() (string, error) {
+	var rid string
+	if err := r.decodeBody("create or lookup", &rid); err != nil {
+		return "", err
+	}
+	return rid, nil
+}
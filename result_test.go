@@ -0,0 +1,73 @@
+package goonep
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestResultDecoding(t *testing.T) {
+	client := replyClient(t, http.StatusOK, `[
+		{"id":1,"status":"ok","result":[[1400000002,21.5],[1400000001,"hot"]]},
+		{"id":2,"status":"ok","result":{"basic":{"type":"dataport","status":"activated","modified":1400000000},"aliases":[],"description":{"name":"temp","format":"float"},"tags":["a"]}},
+		{"id":3,"status":"ok","result":{"dataport":["0123456789012345678901234567890123456789"],"client":[]}},
+		{"id":4,"status":"ok","result":"0123456789012345678901234567890123456789"},
+		{"id":5,"status":"invalid"},
+		{"id":6,"status":"ok","result":[["0123456789012345678901234567890123456789"],[]]}
+	]`)
+
+	b := client.NewBatch("cik")
+	read := b.Read("rid", map[string]interface{}{})
+	info := b.Info("rid", map[string]interface{}{})
+	listing := b.ListingWithOptions([]string{"dataport", "client"}, map[string]interface{}{})
+	lookup := b.Lookup("alias", "temp")
+	missing := b.Lookup("alias", "missing")
+	positional := b.Listing([]string{"dataport", "client"})
+	if _, err := b.Do(); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	result, _ := read.Result()
+	points, err := result.Points()
+	if err != nil || len(points) != 2 {
+		t.Fatalf("Unexpected points: %v %v", points, err)
+	}
+	if points[0].Timestamp != 1400000002 || points[0].Value != json.Number("21.5") || points[1].Value != "hot" {
+		t.Errorf("Unexpected points: %v", points)
+	}
+
+	result, _ = info.Result()
+	resourceInfo, err := result.Info()
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if resourceInfo.Basic.Type != "dataport" || resourceInfo.Description["name"] != "temp" || len(resourceInfo.Tags) != 1 {
+		t.Errorf("Unexpected info: %+v", resourceInfo)
+	}
+
+	result, _ = listing.Result()
+	rids, err := result.Listing()
+	if err != nil || len(rids["dataport"]) != 1 || len(rids["client"]) != 0 {
+		t.Errorf("Unexpected listing: %v %v", rids, err)
+	}
+
+	result, _ = positional.Result()
+	rids, err = result.Listing("dataport", "client")
+	if err != nil || len(rids["dataport"]) != 1 || rids["client"] == nil || len(rids["client"]) != 0 {
+		t.Errorf("Unexpected positional listing: %v %v", rids, err)
+	}
+	if _, err := result.Listing(); err == nil {
+		t.Errorf("Failed: expected a positional listing without types to fail")
+	}
+
+	result, _ = lookup.Result()
+	if rid, err := result.RID(); err != nil || !validCikRid(rid) {
+		t.Errorf("Unexpected rid: %v %v", rid, err)
+	}
+
+	result, _ = missing.Result()
+	if _, err := result.RID(); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Expected ErrInvalid, got %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package goonep
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy decides when and how often a failed request is sent again.
+// A request is retried when it failed with a connection reset or timeout,
+// or was answered with a 5xx or 429 status.
+//
+// Only idempotent requests are retried unless RetryNonIdempotent is set:
+// RPC requests whose calls are all read only (read, info, listing, lookup,
+// ...) and provisioning GET requests.
+type RetryPolicy struct {
+	// MaxAttempts is the number of times a request is sent, counting the
+	// first attempt.
+	MaxAttempts int
+
+	// BaseDelay is the wait before the first retry. It doubles with every
+	// further retry up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+
+	// Jitter spreads each wait randomly by up to this fraction of it, so
+	// that many clients failing together do not retry together.
+	Jitter float64
+
+	// RetryNonIdempotent also retries requests that change data, e.g.
+	// create, record, write or Serialnumber_add. Such a request may be
+	// carried out twice when its response was lost.
+	RetryNonIdempotent bool
+
+	// Retryable, when set, replaces the default decision on which
+	// outcomes are worth another attempt. Exactly one of resp and err is
+	// non-nil.
+	Retryable func(resp *http.Response, err error) bool
+}
+
+// DefaultRetryPolicy is used by clients created with NewClient and by the
+// package level functions
+var DefaultRetryPolicy = &RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   200 * time.Millisecond,
+	MaxDelay:    5 * time.Second,
+	Jitter:      0.2,
+}
+
+// idempotentProcedures are the RPCs that can safely be sent twice
+var idempotentProcedures = map[string]bool{
+	"info":    true,
+	"listing": true,
+	"lookup":  true,
+	"query":   true,
+	"read":    true,
+	"usage":   true,
+	"wait":    true,
+}
+
+// idempotentCalls reports whether every call in calls is idempotent
+func idempotentCalls(calls []interface{}) bool {
+	for _, call := range calls {
+		if !idempotentProcedures[procedureOf(call)] {
+			return false
+		}
+	}
+	return true
+}
+
+// procedureOf returns the procedure name of a call
+func procedureOf(call interface{}) string {
+	if m, ok := call.(map[string]interface{}); ok {
+		procedure, _ := m["procedure"].(string)
+		return procedure
+	}
+	buf, err := json.Marshal(call)
+	if err != nil {
+		return ""
+	}
+	var decoded struct {
+		Procedure string `json:"procedure"`
+	}
+	json.Unmarshal(buf, &decoded)
+	return decoded.Procedure
+}
+
+// shouldRetry reports whether req deserves another attempt after attempt
+// number attempt ended with resp or err
+func (p *RetryPolicy) shouldRetry(req *http.Request, attempt int, idempotent bool, resp *http.Response, err error) bool {
+	if p == nil || attempt >= p.MaxAttempts {
+		return false
+	}
+	if !idempotent && !p.RetryNonIdempotent {
+		return false
+	}
+	if req.Body != nil && req.GetBody == nil {
+		// the body has been consumed and cannot be sent again
+		return false
+	}
+	if err == nil && resp == nil {
+		return false
+	}
+	if p.Retryable != nil {
+		if err != nil {
+			resp = nil
+		}
+		return p.Retryable(resp, err)
+	}
+	if err != nil {
+		return retryableError(err)
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// retryableError reports whether err is a transient network failure
+func retryableError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
+
+// wait sleeps before the retry following attempt, honouring a Retry-After
+// header sent with resp, and returns ctx.Err() if ctx is done first
+func (p *RetryPolicy) wait(ctx context.Context, attempt int, resp *http.Response) error {
+	delay := p.delay(attempt)
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			delay = time.Duration(seconds) * time.Second
+		}
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// delay returns the backoff before the retry following attempt
+func (p *RetryPolicy) delay(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			delay = p.MaxDelay
+			break
+		}
+	}
+	if p.Jitter > 0 {
+		spread := float64(delay) * p.Jitter
+		delay += time.Duration(spread * (2*rand.Float64() - 1))
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	return delay
+}
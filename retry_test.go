@@ -0,0 +1,65 @@
+package goonep
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyClient returns a client whose server fails the first failures
+// requests of every test with 503, and a counter of requests received
+func flakyClient(t *testing.T, failures int32) (*Client, *int32) {
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&count, 1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`[{"id":1,"status":"ok"}]`))
+	}))
+	t.Cleanup(server.Close)
+	client := &Client{
+		Scheme: "http",
+		Host:   strings.TrimPrefix(server.URL, "http://"),
+		Retry:  &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+	}
+	return client, &count
+}
+
+func TestRetry(t *testing.T) {
+	client, count := flakyClient(t, 2)
+	if _, err := client.Read("cik", "rid", map[string]interface{}{}); err != nil {
+		t.Errorf("Failed: %v", err)
+	}
+	if *count != 3 {
+		t.Errorf("Expected 3 attempts, got %d", *count)
+	}
+
+	client, count = flakyClient(t, 2)
+	if _, err := client.Write("cik", "rid", 1); err == nil {
+		t.Errorf("Expected write to fail without retries")
+	}
+	if *count != 1 {
+		t.Errorf("Expected 1 attempt, got %d", *count)
+	}
+
+	client, count = flakyClient(t, 2)
+	client.Retry.RetryNonIdempotent = true
+	if _, err := client.Write("cik", "rid", 1); err != nil {
+		t.Errorf("Failed: %v", err)
+	}
+	if *count != 3 {
+		t.Errorf("Expected 3 attempts, got %d", *count)
+	}
+
+	client, count = flakyClient(t, 5)
+	if _, err := client.Read("cik", "rid", map[string]interface{}{}); err == nil {
+		t.Errorf("Expected read to fail after all attempts")
+	}
+	if *count != 3 {
+		t.Errorf("Expected 3 attempts, got %d", *count)
+	}
+}
@@ -4,38 +4,46 @@ package goonep
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	//"github.com/davecgh/go-spew/spew"
-	"io/ioutil"
+	"errors"
 	"net/http"
-	"strings"
 )
 
 var version = "0.2"
+
+// DomainKey is not used by the library and is kept for compatibility.
 var DomainKey = ""
 
+// InDev makes the package level functions talk to m2-dev.exosite.com.
+// Prefer a Client of your own over this global.
 var InDev = false
 
 // Set this to, e.g., "m2.exosite.com" or "localhost:18393"
+// Prefer a Client of your own over this global.
 var ONEPHost = "m2.exosite.com"
 
 type Response struct {
 	Results []Result
 }
 
+// Result is the outcome of one call. Use Err to find out whether the call
+// failed.
 type Result struct {
-	Id     int         `json:"id",int,omitempty`
-	Body   interface{} `json:"result",string`
-	Status string      `json:"status",string,omitempty`
+	Id     int         `json:"id,omitempty"`
+	Body   interface{} `json:"result"`
+	Status string      `json:"status,omitempty"`
 
-	Error struct {
-		Code    int
-		Message string
-	} `json:"error",omitempty`
+	Error RPCError `json:"error,omitempty"`
 }
 
 // Call is a helper function that carries out HTTP requests for RPC API calls
-func Call(auth interface{}, procedure string, arguments []interface{}) (Response, error) {
+func (c *Client) Call(auth interface{}, procedure string, arguments []interface{}) (Response, error) {
+	return c.CallContext(context.Background(), auth, procedure, arguments)
+}
+
+// CallContext is like Call but gives up when ctx is done
+func (c *Client) CallContext(ctx context.Context, auth interface{}, procedure string, arguments []interface{}) (Response, error) {
 	var calls = []interface{}{
 		map[string]interface{}{
 			"id":        1,
@@ -43,250 +51,568 @@ func Call(auth interface{}, procedure string, arguments []interface{}) (Response
 			"arguments": arguments,
 		},
 	}
-	return CallMulti(auth, calls)
+	return c.CallMultiContext(ctx, auth, calls)
 }
 
-func CallMulti(auth interface{}, calls []interface{}) (Response, error) {
-	client := &http.Client{}
+// CallMulti sends several calls in a single request. The error is an
+// *RPCError when the server refused the request as a whole, and joins a
+// *CallError for each failed call otherwise; the Response holds the results
+// of all calls either way.
+func (c *Client) CallMulti(auth interface{}, calls []interface{}) (Response, error) {
+	return c.CallMultiContext(context.Background(), auth, calls)
+}
 
-	f := Response{}
+// CallMultiContext is like CallMulti but gives up when ctx is done
+func (c *Client) CallMultiContext(ctx context.Context, auth interface{}, calls []interface{}) (Response, error) {
+	calls, parts := c.Limits.splitRecordbatches(calls)
+	resp, err := c.callChunks(ctx, auth, calls)
+	if parts == nil {
+		return resp, err
+	}
+	merged := mergeRecordbatches(resp, parts)
+	var callErr *CallError
+	if err == nil || errors.As(err, &callErr) {
+		err = merged.Err()
+	}
+	return merged, err
+}
 
-	var fullAuth = auth
-	switch auth.(type) {
-	case string:
-		fullAuth = map[string]interface{}{"cik": auth}
-	case interface{}:
-		fullAuth = auth
+// callChunks sends calls in as many requests as the client's Limits need
+func (c *Client) callChunks(ctx context.Context, auth interface{}, calls []interface{}) (Response, error) {
+	if c.Limits.enabled() {
+		chunks, err := c.Limits.split(calls)
+		if err != nil {
+			return Response{}, err
+		}
+		if len(chunks) > 1 {
+			return c.postChunks(ctx, auth, chunks)
+		}
 	}
+	return c.post(ctx, auth, calls)
+}
+
+// post sends calls in a single request
+func (c *Client) post(ctx context.Context, auth interface{}, calls []interface{}) (Response, error) {
+	f := Response{}
 
 	var requestBody = map[string]interface{}{
-		"auth":  fullAuth,
+		"auth":  c.fullAuth(auth),
 		"calls": calls,
 	}
 
-	var serverUrl = ""
-	serverUrl = "http://" + ONEPHost + "/onep:v1/rpc/process"
-	if InDev {
-		serverUrl = "https://m2-dev.exosite.com/onep:v1/rpc/process"
+	buf, err := json.Marshal(requestBody)
+	if err != nil {
+		return f, err
 	}
-
-	buf, _ := json.Marshal(requestBody)
-	requestBodyBuf := bytes.NewBuffer(buf)
-	req, err := http.NewRequest("POST", serverUrl, requestBodyBuf)
+	req, err := http.NewRequest("POST", c.url(RPC_PATH), bytes.NewReader(buf))
 	if err != nil {
 		return f, err
 	}
 	req.Header.Add("Content-Type", "application/json; charset=utf-8")
-	req.Header.Add("User-Agent", "goonep "+version)
-
-	resp, err := client.Do(req)
 
+	resp, body, err := c.do(ctx, req, idempotentCalls(calls))
 	if err != nil {
 		return f, err
 	}
+	return decodeResponse(resp.StatusCode, body)
+}
 
-	defer resp.Body.Close()
+// decodeResponse turns an RPC response body into a Response. The server
+// answers either with a list of call results or, when it refuses the whole
+// request, with a single error object.
+func decodeResponse(statusCode int, body []byte) (Response, error) {
+	f := Response{}
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return f, err
+	var envelope struct {
+		Error *RPCError `json:"error"`
+	}
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, &envelope); err == nil && envelope.Error != nil {
+			return f, envelope.Error
+		}
 	}
-	d := json.NewDecoder(strings.NewReader(string(body)))
+
+	d := json.NewDecoder(bytes.NewReader(body))
 	d.UseNumber()
-	err2 := d.Decode(&(f.Results))
-	//err2 := json.Unmarshal(body, &(f.Results))
-	return f, err2
-	/*
-		if err2 != nil {
-			fmt.Println("Err2: ", err2)
-			//panic(err2)
-			return f, err2
+	if err := d.Decode(&(f.Results)); err != nil {
+		if statusCode >= 300 {
+			return f, &RPCError{Code: statusCode, Message: http.StatusText(statusCode)}
 		}
-	*/
-	// uncomment to print response body (for debugging)
-	//fmt.Println(string(body))
+		return f, err
+	}
 
-	//fmt.Printf("%v\n", f)
-	//b, err := json.MarshalIndent(f, "", "  ")
-	//if err != nil {
-	//	panic(err)
-	//}
-	//fmt.Printf("%v\n", bytes.NewBuffer(b))
+	return f, f.Err()
+}
+
+// Call carries out a single RPC call with the default client
+func Call(auth interface{}, procedure string, arguments []interface{}) (Response, error) {
+	return defaultClient().Call(auth, procedure, arguments)
+}
 
-	// TODO: RPC error checking
+// CallContext is like Call but gives up when ctx is done
+func CallContext(ctx context.Context, auth interface{}, procedure string, arguments []interface{}) (Response, error) {
+	return defaultClient().CallContext(ctx, auth, procedure, arguments)
+}
+
+// CallMulti sends several calls in a single request with the default client
+func CallMulti(auth interface{}, calls []interface{}) (Response, error) {
+	return defaultClient().CallMulti(auth, calls)
+}
 
-	//return f, nil
+// CallMultiContext is like CallMulti but gives up when ctx is done
+func CallMultiContext(ctx context.Context, auth interface{}, calls []interface{}) (Response, error) {
+	return defaultClient().CallMultiContext(ctx, auth, calls)
 }
 
 // the following functions implement the RPC APIs their names correspond to
 
-func Activate(auth interface{}, codetype string, code string) (Response, error) {
+func (c *Client) Activate(auth interface{}, codetype string, code string) (Response, error) {
+	return c.ActivateContext(context.Background(), auth, codetype, code)
+}
+
+func (c *Client) ActivateContext(ctx context.Context, auth interface{}, codetype string, code string) (Response, error) {
 	var arguments = []interface{}{
 		codetype,
 		code,
 	}
-	return Call(auth, "activate", arguments)
+	return c.CallContext(ctx, auth, "activate", arguments)
 }
 
-func Create(auth interface{}, ttype string, desc interface{}) (Response, error) {
+func (c *Client) Create(auth interface{}, ttype string, desc interface{}) (Response, error) {
+	return c.CreateContext(context.Background(), auth, ttype, desc)
+}
+
+func (c *Client) CreateContext(ctx context.Context, auth interface{}, ttype string, desc interface{}) (Response, error) {
 	var arguments = []interface{}{
 		ttype,
 		desc,
 	}
-	return Call(auth, "create", arguments)
+	return c.CallContext(ctx, auth, "create", arguments)
 }
 
-func Deactivate(auth interface{}, codetype string, code string) (Response, error) {
+func (c *Client) Deactivate(auth interface{}, codetype string, code string) (Response, error) {
+	return c.DeactivateContext(context.Background(), auth, codetype, code)
+}
+
+func (c *Client) DeactivateContext(ctx context.Context, auth interface{}, codetype string, code string) (Response, error) {
 	var arguments = []interface{}{
 		codetype,
 		code,
 	}
-	return Call(auth, "deactivate", arguments)
+	return c.CallContext(ctx, auth, "deactivate", arguments)
 }
 
-func Drop(auth interface{}, rid interface{}) (Response, error) {
+func (c *Client) Drop(auth interface{}, rid interface{}) (Response, error) {
+	return c.DropContext(context.Background(), auth, rid)
+}
+
+func (c *Client) DropContext(ctx context.Context, auth interface{}, rid interface{}) (Response, error) {
 	var arguments = []interface{}{
 		rid,
 	}
-	return Call(auth, "drop", arguments)
+	return c.CallContext(ctx, auth, "drop", arguments)
 }
 
-func Flush(auth interface{}, rid interface{}) (Response, error) {
+func (c *Client) Flush(auth interface{}, rid interface{}) (Response, error) {
+	return c.FlushContext(context.Background(), auth, rid)
+}
+
+func (c *Client) FlushContext(ctx context.Context, auth interface{}, rid interface{}) (Response, error) {
 	var arguments = []interface{}{
 		rid,
 	}
-	return Call(auth, "flush", arguments)
+	return c.CallContext(ctx, auth, "flush", arguments)
 }
 
-func Info(auth interface{}, rid interface{}, options interface{}) (Response, error) {
+func (c *Client) Info(auth interface{}, rid interface{}, options interface{}) (Response, error) {
+	return c.InfoContext(context.Background(), auth, rid, options)
+}
+
+func (c *Client) InfoContext(ctx context.Context, auth interface{}, rid interface{}, options interface{}) (Response, error) {
 	var arguments = []interface{}{
 		rid,
 		options,
 	}
-	return Call(auth, "info", arguments)
+	return c.CallContext(ctx, auth, "info", arguments)
 }
 
-func Listing(auth interface{}, types interface{}) (Response, error) {
+func (c *Client) Listing(auth interface{}, types interface{}) (Response, error) {
+	return c.ListingContext(context.Background(), auth, types)
+}
+
+func (c *Client) ListingContext(ctx context.Context, auth interface{}, types interface{}) (Response, error) {
 	var arguments = []interface{}{
 		types,
 	}
-	return Call(auth, "listing", arguments)
+	return c.CallContext(ctx, auth, "listing", arguments)
 }
 
-func Lookup(auth interface{}, ttype string, alias string) (Response, error) {
+// ListingWithOptions lists resources keyed by type; options can hold e.g.
+// "owned", "aliased", "public" or "tagged" filters
+func (c *Client) ListingWithOptions(auth interface{}, types interface{}, options interface{}) (Response, error) {
+	return c.ListingWithOptionsContext(context.Background(), auth, types, options)
+}
+
+func (c *Client) ListingWithOptionsContext(ctx context.Context, auth interface{}, types interface{}, options interface{}) (Response, error) {
+	var arguments = []interface{}{
+		types,
+		options,
+	}
+	return c.CallContext(ctx, auth, "listing", arguments)
+}
+
+func (c *Client) Lookup(auth interface{}, ttype string, alias string) (Response, error) {
+	return c.LookupContext(context.Background(), auth, ttype, alias)
+}
+
+func (c *Client) LookupContext(ctx context.Context, auth interface{}, ttype string, alias string) (Response, error) {
 	var arguments = []interface{}{
 		ttype,
 		alias,
 	}
-	return Call(auth, "lookup", arguments)
+	return c.CallContext(ctx, auth, "lookup", arguments)
 }
 
 // oneMap implements the map RPC (name difference due to naming conflict)
-func OneMap(auth interface{}, rid interface{}, alias string) (Response, error) {
+func (c *Client) OneMap(auth interface{}, rid interface{}, alias string) (Response, error) {
+	return c.OneMapContext(context.Background(), auth, rid, alias)
+}
+
+func (c *Client) OneMapContext(ctx context.Context, auth interface{}, rid interface{}, alias string) (Response, error) {
 	var arguments = []interface{}{
 		"alias",
 		rid,
 		alias,
 	}
-	return Call(auth, "map", arguments)
+	return c.CallContext(ctx, auth, "map", arguments)
 }
 
-func Query(auth interface{}, rid, filter, sel, options interface{}) (Response, error) {
+func (c *Client) Query(auth interface{}, rid, filter, sel, options interface{}) (Response, error) {
+	return c.QueryContext(context.Background(), auth, rid, filter, sel, options)
+}
+
+func (c *Client) QueryContext(ctx context.Context, auth interface{}, rid, filter, sel, options interface{}) (Response, error) {
 	var arguments = []interface{}{
 		rid,
 		filter,
 		sel,
 		options,
 	}
-	return Call(auth, "query", arguments)
+	return c.CallContext(ctx, auth, "query", arguments)
 }
 
-func Read(auth interface{}, rid interface{}, options interface{}) (Response, error) {
+func (c *Client) Read(auth interface{}, rid interface{}, options interface{}) (Response, error) {
+	return c.ReadContext(context.Background(), auth, rid, options)
+}
+
+func (c *Client) ReadContext(ctx context.Context, auth interface{}, rid interface{}, options interface{}) (Response, error) {
 	var arguments = []interface{}{
 		rid,
 		options,
 	}
-	return Call(auth, "read", arguments)
+	return c.CallContext(ctx, auth, "read", arguments)
 }
 
-func Record(auth interface{}, rid interface{}, entries interface{}, options interface{}) (Response, error) {
+func (c *Client) Record(auth interface{}, rid interface{}, entries interface{}, options interface{}) (Response, error) {
+	return c.RecordContext(context.Background(), auth, rid, entries, options)
+}
+
+func (c *Client) RecordContext(ctx context.Context, auth interface{}, rid interface{}, entries interface{}, options interface{}) (Response, error) {
 	var arguments = []interface{}{
 		rid,
 		entries,
 		options,
 	}
 	//fmt.Printf("Arguments: %+v", arguments)
-	return Call(auth, "record", arguments)
+	return c.CallContext(ctx, auth, "record", arguments)
 }
 
-func Recordbatch(auth interface{}, rid interface{}, entries interface{}) (Response, error) {
+func (c *Client) Recordbatch(auth interface{}, rid interface{}, entries interface{}) (Response, error) {
+	return c.RecordbatchContext(context.Background(), auth, rid, entries)
+}
+
+// RecordbatchContext splits entries over several calls when they hold more
+// points than the client's Limits allow
+func (c *Client) RecordbatchContext(ctx context.Context, auth interface{}, rid interface{}, entries interface{}) (Response, error) {
 	var arguments = []interface{}{
 		rid,
 		entries,
 	}
-	return Call(auth, "recordbatch", arguments)
+	return c.CallContext(ctx, auth, "recordbatch", arguments)
 }
 
-func Revoke(auth interface{}, codetype string, code string) (Response, error) {
+func (c *Client) Revoke(auth interface{}, codetype string, code string) (Response, error) {
+	return c.RevokeContext(context.Background(), auth, codetype, code)
+}
+
+func (c *Client) RevokeContext(ctx context.Context, auth interface{}, codetype string, code string) (Response, error) {
 	var arguments = []interface{}{
 		codetype,
 		code,
 	}
-	return Call(auth, "revoke", arguments)
+	return c.CallContext(ctx, auth, "revoke", arguments)
 }
 
-func Share(auth interface{}, rid interface{}, options interface{}) (Response, error) {
+func (c *Client) Share(auth interface{}, rid interface{}, options interface{}) (Response, error) {
+	return c.ShareContext(context.Background(), auth, rid, options)
+}
+
+func (c *Client) ShareContext(ctx context.Context, auth interface{}, rid interface{}, options interface{}) (Response, error) {
 	var arguments = []interface{}{
 		rid,
 		options,
 	}
-	return Call(auth, "share", arguments)
+	return c.CallContext(ctx, auth, "share", arguments)
 }
 
-func Unmap(auth interface{}, alias string) (Response, error) {
+func (c *Client) Unmap(auth interface{}, alias string) (Response, error) {
+	return c.UnmapContext(context.Background(), auth, alias)
+}
+
+func (c *Client) UnmapContext(ctx context.Context, auth interface{}, alias string) (Response, error) {
 	var arguments = []interface{}{
 		"alias",
 		alias,
 	}
-	return Call(auth, "unmap", arguments)
+	return c.CallContext(ctx, auth, "unmap", arguments)
 }
 
-func Update(auth interface{}, rid interface{}, desc interface{}) (Response, error) {
+func (c *Client) Update(auth interface{}, rid interface{}, desc interface{}) (Response, error) {
+	return c.UpdateContext(context.Background(), auth, rid, desc)
+}
+
+func (c *Client) UpdateContext(ctx context.Context, auth interface{}, rid interface{}, desc interface{}) (Response, error) {
 	var arguments = []interface{}{
 		rid,
 		desc,
 	}
-	return Call(auth, "update", arguments)
+	return c.CallContext(ctx, auth, "update", arguments)
 }
 
-func Usage(auth interface{}, rid interface{}, metric string, starttime int, endtime string) (Response, error) {
+func (c *Client) Usage(auth interface{}, rid interface{}, metric string, starttime int, endtime string) (Response, error) {
+	return c.UsageContext(context.Background(), auth, rid, metric, starttime, endtime)
+}
+
+func (c *Client) UsageContext(ctx context.Context, auth interface{}, rid interface{}, metric string, starttime int, endtime string) (Response, error) {
 	var arguments = []interface{}{
 		rid,
 		metric,
 		starttime,
 		endtime,
 	}
-	return Call(auth, "usage", arguments)
+	return c.CallContext(ctx, auth, "usage", arguments)
 }
 
-func Wait(auth interface{}, rid interface{}) (Response, error) {
+func (c *Client) Wait(auth interface{}, rid interface{}) (Response, error) {
+	return c.WaitContext(context.Background(), auth, rid)
+}
+
+func (c *Client) WaitContext(ctx context.Context, auth interface{}, rid interface{}) (Response, error) {
 	var arguments = []interface{}{
 		rid,
 	}
-	return Call(auth, "wait", arguments)
+	return c.CallContext(ctx, auth, "wait", arguments)
 }
 
-func Write(auth interface{}, rid interface{}, value interface{}) (Response, error) {
+func (c *Client) Write(auth interface{}, rid interface{}, value interface{}) (Response, error) {
+	return c.WriteContext(context.Background(), auth, rid, value)
+}
+
+func (c *Client) WriteContext(ctx context.Context, auth interface{}, rid interface{}, value interface{}) (Response, error) {
 	var arguments = []interface{}{
 		rid,
 		value,
 	}
-	return Call(auth, "write", arguments)
+	return c.CallContext(ctx, auth, "write", arguments)
 }
 
-func Writegroup(auth interface{}, entries interface{}) (Response, error) {
+func (c *Client) Writegroup(auth interface{}, entries interface{}) (Response, error) {
+	return c.WritegroupContext(context.Background(), auth, entries)
+}
+
+func (c *Client) WritegroupContext(ctx context.Context, auth interface{}, entries interface{}) (Response, error) {
 	var arguments = []interface{}{
 		entries,
 	}
-	return Call(auth, "writegroup", arguments)
+	return c.CallContext(ctx, auth, "writegroup", arguments)
+}
+
+// the package level functions below call their Client counterparts on the
+// default client
+
+func Activate(auth interface{}, codetype string, code string) (Response, error) {
+	return defaultClient().Activate(auth, codetype, code)
+}
+
+func ActivateContext(ctx context.Context, auth interface{}, codetype string, code string) (Response, error) {
+	return defaultClient().ActivateContext(ctx, auth, codetype, code)
+}
+
+func Create(auth interface{}, ttype string, desc interface{}) (Response, error) {
+	return defaultClient().Create(auth, ttype, desc)
+}
+
+func CreateContext(ctx context.Context, auth interface{}, ttype string, desc interface{}) (Response, error) {
+	return defaultClient().CreateContext(ctx, auth, ttype, desc)
+}
+
+func Deactivate(auth interface{}, codetype string, code string) (Response, error) {
+	return defaultClient().Deactivate(auth, codetype, code)
+}
+
+func DeactivateContext(ctx context.Context, auth interface{}, codetype string, code string) (Response, error) {
+	return defaultClient().DeactivateContext(ctx, auth, codetype, code)
+}
+
+func Drop(auth interface{}, rid interface{}) (Response, error) {
+	return defaultClient().Drop(auth, rid)
+}
+
+func DropContext(ctx context.Context, auth interface{}, rid interface{}) (Response, error) {
+	return defaultClient().DropContext(ctx, auth, rid)
+}
+
+func Flush(auth interface{}, rid interface{}) (Response, error) {
+	return defaultClient().Flush(auth, rid)
+}
+
+func FlushContext(ctx context.Context, auth interface{}, rid interface{}) (Response, error) {
+	return defaultClient().FlushContext(ctx, auth, rid)
+}
+
+func Info(auth interface{}, rid interface{}, options interface{}) (Response, error) {
+	return defaultClient().Info(auth, rid, options)
+}
+
+func InfoContext(ctx context.Context, auth interface{}, rid interface{}, options interface{}) (Response, error) {
+	return defaultClient().InfoContext(ctx, auth, rid, options)
+}
+
+func Listing(auth interface{}, types interface{}) (Response, error) {
+	return defaultClient().Listing(auth, types)
+}
+
+func ListingContext(ctx context.Context, auth interface{}, types interface{}) (Response, error) {
+	return defaultClient().ListingContext(ctx, auth, types)
+}
+
+func ListingWithOptions(auth interface{}, types interface{}, options interface{}) (Response, error) {
+	return defaultClient().ListingWithOptions(auth, types, options)
+}
+
+func ListingWithOptionsContext(ctx context.Context, auth interface{}, types interface{}, options interface{}) (Response, error) {
+	return defaultClient().ListingWithOptionsContext(ctx, auth, types, options)
+}
+
+func Lookup(auth interface{}, ttype string, alias string) (Response, error) {
+	return defaultClient().Lookup(auth, ttype, alias)
+}
+
+func LookupContext(ctx context.Context, auth interface{}, ttype string, alias string) (Response, error) {
+	return defaultClient().LookupContext(ctx, auth, ttype, alias)
+}
+
+func OneMap(auth interface{}, rid interface{}, alias string) (Response, error) {
+	return defaultClient().OneMap(auth, rid, alias)
+}
+
+func OneMapContext(ctx context.Context, auth interface{}, rid interface{}, alias string) (Response, error) {
+	return defaultClient().OneMapContext(ctx, auth, rid, alias)
+}
+
+func Query(auth interface{}, rid, filter, sel, options interface{}) (Response, error) {
+	return defaultClient().Query(auth, rid, filter, sel, options)
+}
+
+func QueryContext(ctx context.Context, auth interface{}, rid, filter, sel, options interface{}) (Response, error) {
+	return defaultClient().QueryContext(ctx, auth, rid, filter, sel, options)
+}
+
+func Read(auth interface{}, rid interface{}, options interface{}) (Response, error) {
+	return defaultClient().Read(auth, rid, options)
+}
+
+func ReadContext(ctx context.Context, auth interface{}, rid interface{}, options interface{}) (Response, error) {
+	return defaultClient().ReadContext(ctx, auth, rid, options)
+}
+
+func Record(auth interface{}, rid interface{}, entries interface{}, options interface{}) (Response, error) {
+	return defaultClient().Record(auth, rid, entries, options)
+}
+
+func RecordContext(ctx context.Context, auth interface{}, rid interface{}, entries interface{}, options interface{}) (Response, error) {
+	return defaultClient().RecordContext(ctx, auth, rid, entries, options)
+}
+
+func Recordbatch(auth interface{}, rid interface{}, entries interface{}) (Response, error) {
+	return defaultClient().Recordbatch(auth, rid, entries)
+}
+
+func RecordbatchContext(ctx context.Context, auth interface{}, rid interface{}, entries interface{}) (Response, error) {
+	return defaultClient().RecordbatchContext(ctx, auth, rid, entries)
+}
+
+func Revoke(auth interface{}, codetype string, code string) (Response, error) {
+	return defaultClient().Revoke(auth, codetype, code)
+}
+
+func RevokeContext(ctx context.Context, auth interface{}, codetype string, code string) (Response, error) {
+	return defaultClient().RevokeContext(ctx, auth, codetype, code)
+}
+
+func Share(auth interface{}, rid interface{}, options interface{}) (Response, error) {
+	return defaultClient().Share(auth, rid, options)
+}
+
+func ShareContext(ctx context.Context, auth interface{}, rid interface{}, options interface{}) (Response, error) {
+	return defaultClient().ShareContext(ctx, auth, rid, options)
+}
+
+func Unmap(auth interface{}, alias string) (Response, error) {
+	return defaultClient().Unmap(auth, alias)
+}
+
+func UnmapContext(ctx context.Context, auth interface{}, alias string) (Response, error) {
+	return defaultClient().UnmapContext(ctx, auth, alias)
+}
+
+func Update(auth interface{}, rid interface{}, desc interface{}) (Response, error) {
+	return defaultClient().Update(auth, rid, desc)
+}
+
+func UpdateContext(ctx context.Context, auth interface{}, rid interface{}, desc interface{}) (Response, error) {
+	return defaultClient().UpdateContext(ctx, auth, rid, desc)
+}
+
+func Usage(auth interface{}, rid interface{}, metric string, starttime int, endtime string) (Response, error) {
+	return defaultClient().Usage(auth, rid, metric, starttime, endtime)
+}
+
+func UsageContext(ctx context.Context, auth interface{}, rid interface{}, metric string, starttime int, endtime string) (Response, error) {
+	return defaultClient().UsageContext(ctx, auth, rid, metric, starttime, endtime)
+}
+
+func Wait(auth interface{}, rid interface{}) (Response, error) {
+	return defaultClient().Wait(auth, rid)
+}
+
+func WaitContext(ctx context.Context, auth interface{}, rid interface{}) (Response, error) {
+	return defaultClient().WaitContext(ctx, auth, rid)
+}
+
+func Write(auth interface{}, rid interface{}, value interface{}) (Response, error) {
+	return defaultClient().Write(auth, rid, value)
+}
+
+func WriteContext(ctx context.Context, auth interface{}, rid interface{}, value interface{}) (Response, error) {
+	return defaultClient().WriteContext(ctx, auth, rid, value)
+}
+
+func Writegroup(auth interface{}, entries interface{}) (Response, error) {
+	return defaultClient().Writegroup(auth, entries)
+}
+
+func WritegroupContext(ctx context.Context, auth interface{}, entries interface{}) (Response, error) {
+	return defaultClient().WritegroupContext(ctx, auth, entries)
 }
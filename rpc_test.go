@@ -1,30 +1,26 @@
 package goonep
 
 import (
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"math/rand"
-	"net/http"
+	"net/http/httptest"
+	"os"
 	"runtime"
-	"strconv"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/exosite-labs/goonep/onepfake"
 )
 
 var alias = "X1"
 var alias2 = "X2"
 
 // errorCheckRPC checks for RPC API HTTP errors
-func errorCheckRPC(t *testing.T, body Response, err interface{}, line int) {
+func errorCheckRPC(t *testing.T, body Response, err error, line int) {
 	if err != nil {
-		t.Errorf("Failed: %v", err)
-	}
-	if body.Results[0].Status == "invalid" {
-		t.Errorf("Failed: %v", "RPC status response was invalid on line "+strconv.Itoa(line+1))
-	}
-	if body.Results[0].Status == "badarg" {
-		t.Errorf("Failed: %v", "RPC status response was badarg on line "+strconv.Itoa(line+1))
+		t.Errorf("Failed: %v on line %d", err, line+1)
 	}
 }
 
@@ -45,22 +41,29 @@ func validCikRid(s string) bool {
 	return true
 }
 
-// Returns a new temporary CIK to use for tests.
-func genCik() string {
-	resp, err := http.Get("https://cik.herokuapp.com")
-	if err != nil {
-		panic(fmt.Sprintf("Failed to generate CIK: %s", err.Error()))
-	}
+// fake is the stand-in platform the package level functions talk to
+var fake *onepfake.Server
 
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		panic(fmt.Sprintf("Failed to read response body from CIK fountain: %s", err.Error()))
+func TestMain(m *testing.M) {
+	fake = onepfake.New()
+	server := httptest.NewServer(fake)
+
+	// provisioning calls go to the fake as well
+	DefaultClient = &Client{
+		Scheme: "http",
+		Host:   strings.TrimPrefix(server.URL, "http://"),
 	}
 
-	cik := string(body)
-	if !validCikRid(string(cik)) {
-		panic(fmt.Sprintf("Invalid CIK returned by CIK fountain: %s", cik))
+	code := m.Run()
+	server.Close()
+	os.Exit(code)
+}
+
+// Returns a new temporary CIK to use for tests.
+func genCik() string {
+	cik := fake.NewClient()
+	if !validCikRid(cik) {
+		panic(fmt.Sprintf("Invalid CIK returned by fake: %s", cik))
 	}
 	return cik
 }
@@ -89,6 +92,9 @@ func TestMainRPC(t *testing.T) {
 
 	// lookup rid of alias X1, if doesn't exist then create + map
 	rid1, err := Lookup(cik, "alias", alias)
+	if err != nil && !errors.Is(err, ErrInvalid) {
+		t.Errorf("Failed: %v", err)
+	}
 
 	rid1Body = rid1.Results[0].Body
 	if rid1.Results[0].Status == "invalid" {
@@ -103,7 +109,7 @@ func TestMainRPC(t *testing.T) {
 
 	// lookup rid of alias X2, if doesn't exist then create + map
 	rid2, err = Lookup(cik, "alias", alias2)
-	if err != nil {
+	if err != nil && !errors.Is(err, ErrInvalid) {
 		t.Errorf("Failed: %v", err)
 	}
 	rid2Body = rid2.Results[0].Body
@@ -0,0 +1,327 @@
+package goonep
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// DefaultImportBatchSize is the number of serial numbers ImportSerialNumbers
+// adds per request unless told otherwise
+var DefaultImportBatchSize = 500
+
+// MaxSerialNumberLength is the longest serial number ValidateSerialNumber
+// accepts
+const MaxSerialNumberLength = 128
+
+// ImportFormat is the encoding of the serial numbers read by
+// ImportSerialNumbers
+type ImportFormat int
+
+const (
+	// ImportCSV reads comma separated records, optionally starting with a
+	// header row
+	ImportCSV ImportFormat = iota
+
+	// ImportNDJSON reads one JSON object per line
+	ImportNDJSON
+)
+
+// ImportOptions tune ImportSerialNumbers
+type ImportOptions struct {
+	Format ImportFormat
+
+	// SNField names the CSV column or JSON field holding the serial number.
+	// Empty means "sn". A CSV file whose first row has no column of that
+	// name has no header. Other columns are ignored.
+	SNField string
+
+	// SNColumn is the column holding the serial number in a CSV file
+	// without header, counting from 0
+	SNColumn int
+
+	// BatchSize is the number of serial numbers added per request. Zero
+	// means DefaultImportBatchSize.
+	BatchSize int
+
+	// Validate checks each serial number. Nil means ValidateSerialNumber.
+	Validate func(sn string) error
+
+	// Progress, when set, is called after every batch that was added.
+	// Sending to a channel from it reports progress to another goroutine.
+	Progress func(ImportProgress)
+
+	// Checkpoint resumes an import that failed: the first Checkpoint
+	// serial numbers of the same input are not added again. Use the
+	// Checkpoint of the ImportResult the failed import returned.
+	Checkpoint int
+}
+
+// ImportProgress tells how far an import got
+type ImportProgress struct {
+	// Done is the number of serial numbers added so far, including those
+	// skipped by the checkpoint. Total is the number of valid, unique
+	// serial numbers read.
+	Done  int
+	Total int
+
+	// Batches is the number of requests made so far
+	Batches int
+}
+
+// InvalidSerialNumber is a serial number ImportSerialNumbers rejected.
+// Line is the line of the input it was read from.
+type InvalidSerialNumber struct {
+	Line int
+	SN   string
+	Err  error
+}
+
+// ImportResult summarizes an import
+type ImportResult struct {
+	// Imported is the number of serial numbers added by this import, not
+	// counting those skipped by the checkpoint
+	Imported int
+
+	// Checkpoint is the number of serial numbers of the input that are
+	// known to be added. After a failure, pass it in ImportOptions to
+	// resume.
+	Checkpoint int
+
+	// Duplicates are the serial numbers that appeared more than once in
+	// the input. Each is only added once.
+	Duplicates []string
+
+	// Invalid are the serial numbers that failed validation and were not
+	// added
+	Invalid []InvalidSerialNumber
+}
+
+// ValidateSerialNumber rejects serial numbers that are empty, longer than
+// MaxSerialNumberLength or contain spaces, control characters or commas,
+// which would break the serial number listing
+func ValidateSerialNumber(sn string) error {
+	if sn == "" {
+		return errors.New("goonep: empty serial number")
+	}
+	if len(sn) > MaxSerialNumberLength {
+		return fmt.Errorf("goonep: serial number longer than %d bytes", MaxSerialNumberLength)
+	}
+	for _, r := range sn {
+		if r == ',' || unicode.IsSpace(r) || unicode.IsControl(r) || r == unicode.ReplacementChar {
+			return fmt.Errorf("goonep: serial number %q contains %q", sn, r)
+		}
+	}
+	return nil
+}
+
+// snRecord is a serial number read from an import and the line it was on
+type snRecord struct {
+	line int
+	sn   string
+}
+
+// readCSVSerialNumbers reads the serial numbers of a CSV import from the
+// column named field, or from column when there is no header
+func readCSVSerialNumbers(r io.Reader, field string, column int) ([]snRecord, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	var records []snRecord
+	for first := true; ; first = false {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("goonep: reading serial numbers: %w", err)
+		}
+		if first {
+			if header := csvHeader(row, field); header >= 0 {
+				column = header
+				continue
+			}
+		}
+		line, _ := reader.FieldPos(0)
+		var sn string
+		if column < len(row) {
+			sn = strings.TrimSpace(row[column])
+		}
+		records = append(records, snRecord{line: line, sn: sn})
+	}
+}
+
+// csvHeader returns the column of field when row is a header, or -1
+func csvHeader(row []string, field string) int {
+	for i, name := range row {
+		if strings.EqualFold(strings.TrimSpace(name), field) {
+			return i
+		}
+	}
+	return -1
+}
+
+// readNDJSONSerialNumbers reads the serial numbers of an NDJSON import.
+// Blank lines are skipped.
+func readNDJSONSerialNumbers(r io.Reader, field string) ([]snRecord, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+
+	var records []snRecord
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		decoder := json.NewDecoder(bytes.NewReader(text))
+		decoder.UseNumber()
+		var object map[string]interface{}
+		if err := decoder.Decode(&object); err != nil {
+			return nil, fmt.Errorf("goonep: reading serial numbers at line %d: %w", line, err)
+		}
+		var sn string
+		switch value := object[field].(type) {
+		case string:
+			sn = strings.TrimSpace(value)
+		case json.Number:
+			sn = value.String()
+		}
+		records = append(records, snRecord{line: line, sn: sn})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("goonep: reading serial numbers: %w", err)
+	}
+	return records, nil
+}
+
+// ImportSerialNumbers reads serial numbers from r and adds them to model in
+// batches. Invalid serial numbers are skipped and duplicates only added
+// once; both are listed in the result. The whole input is read and checked
+// before the first batch is sent, so a malformed file adds nothing.
+//
+// When a batch fails the error is returned along with a result whose
+// Checkpoint is where to resume from.
+func (c *Client) ImportSerialNumbers(ctx context.Context, model string, r io.Reader, opts ImportOptions) (ImportResult, error) {
+	return c.provModel().importSerialNumbers(ctx, model, r, opts)
+}
+
+// importSerialNumbers is ImportSerialNumbers authenticated the way m is
+// managed
+func (m ProvModel) importSerialNumbers(ctx context.Context, model string, r io.Reader, opts ImportOptions) (ImportResult, error) {
+	var result ImportResult
+
+	field := opts.SNField
+	if field == "" {
+		field = "sn"
+	}
+	validate := opts.Validate
+	if validate == nil {
+		validate = ValidateSerialNumber
+	}
+	size := opts.BatchSize
+	if size <= 0 {
+		size = DefaultImportBatchSize
+	}
+
+	var records []snRecord
+	var err error
+	switch opts.Format {
+	case ImportCSV:
+		records, err = readCSVSerialNumbers(r, field, opts.SNColumn)
+	case ImportNDJSON:
+		records, err = readNDJSONSerialNumbers(r, field)
+	default:
+		err = fmt.Errorf("goonep: unknown import format %d", opts.Format)
+	}
+	if err != nil {
+		return result, err
+	}
+
+	var sns []string
+	seen := map[string]bool{}
+	for _, record := range records {
+		if err := validate(record.sn); err != nil {
+			result.Invalid = append(result.Invalid, InvalidSerialNumber{Line: record.line, SN: record.sn, Err: err})
+			continue
+		}
+		if seen[record.sn] {
+			result.Duplicates = append(result.Duplicates, record.sn)
+			continue
+		}
+		seen[record.sn] = true
+		sns = append(sns, record.sn)
+	}
+
+	if opts.Checkpoint > len(sns) {
+		return result, fmt.Errorf("goonep: checkpoint %d is past the %d serial numbers read", opts.Checkpoint, len(sns))
+	}
+	result.Checkpoint = opts.Checkpoint
+	progress := ImportProgress{Done: opts.Checkpoint, Total: len(sns)}
+	for start := opts.Checkpoint; start < len(sns); start += size {
+		end := start + size
+		if end > len(sns) {
+			end = len(sns)
+		}
+		if err := Serialnumber_add_batchContext(ctx, m, "", model, sns[start:end]); err != nil {
+			return result, fmt.Errorf("goonep: adding serial numbers %d to %d: %w", start+1, end, err)
+		}
+		result.Imported += end - start
+		result.Checkpoint = end
+
+		progress.Done = end
+		progress.Batches++
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+	}
+	return result, nil
+}
+
+// ExportSerialNumbers writes every serial number of model to w as CSV with
+// a "sn,rid,extra" header, which ImportSerialNumbers reads back. It returns
+// the number of serial numbers written.
+func (c *Client) ExportSerialNumbers(ctx context.Context, model string, w io.Writer) (int, error) {
+	return c.provModel().exportSerialNumbers(ctx, model, w)
+}
+
+// exportSerialNumbers is ExportSerialNumbers authenticated the way m is
+// managed
+func (m ProvModel) exportSerialNumbers(ctx context.Context, model string, w io.Writer) (int, error) {
+	writer := csv.NewWriter(w)
+	// the rows written are flushed on failure too
+	n := 0
+	err := writer.Write([]string{"sn", "rid", "extra"})
+	it := m.serialNumbers(ctx, model)
+	for err == nil && it.Next() {
+		entry := it.Entry()
+		if err = writer.Write([]string{entry.SN, entry.Rid, entry.Extra}); err == nil {
+			n++
+		}
+	}
+	writer.Flush()
+	if err != nil {
+		return n, err
+	}
+	if err := it.Err(); err != nil {
+		return n, fmt.Errorf("goonep: listing serial numbers after %d: %w", n, err)
+	}
+	return n, writer.Error()
+}
+
+// the package level functions below call their Client counterparts on the
+// default client
+
+func ImportSerialNumbers(ctx context.Context, model string, r io.Reader, opts ImportOptions) (ImportResult, error) {
+	return defaultClient().ImportSerialNumbers(ctx, model, r, opts)
+}
+
+func ExportSerialNumbers(ctx context.Context, model string, w io.Writer) (int, error) {
+	return defaultClient().ExportSerialNumbers(ctx, model, w)
+}
@@ -0,0 +1,122 @@
+package goonep
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestImportSerialNumbers(t *testing.T) {
+	setupProvision(t)
+	var model = "MyBulkModel" + strconv.Itoa(rand.Intn(10000000))
+
+	resp, _ := Lookup(clonecik, "alias", "")
+	if err := Model_create(ProvModel{}, vendortoken, model, resp.Results[0].Body.(string), true, true, true); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	defer Model_remove(ProvModel{}, vendortoken, model)
+
+	client := *DefaultClient
+	client.VendorToken = vendortoken
+
+	var csvFile strings.Builder
+	csvFile.WriteString("extra,sn\n")
+	for i := 0; i < 12; i++ {
+		fmt.Fprintf(&csvFile, "\"made, today\",sn%03d\n", i)
+	}
+	csvFile.WriteString("again,sn003\n,bad sn\n,\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var progress []ImportProgress
+	opts := ImportOptions{BatchSize: 5, Progress: func(p ImportProgress) {
+		progress = append(progress, p)
+		cancel()
+	}}
+	result, err := client.ImportSerialNumbers(ctx, model, strings.NewReader(csvFile.String()), opts)
+	if err == nil || result.Checkpoint != 5 || result.Imported != 5 {
+		t.Errorf("Failed: expected to stop after one batch, got %+v %v", result, err)
+	}
+	if len(result.Duplicates) != 1 || result.Duplicates[0] != "sn003" {
+		t.Errorf("Failed: unexpected duplicates %v", result.Duplicates)
+	}
+	if len(result.Invalid) != 2 || result.Invalid[0].Line != 15 || result.Invalid[1].SN != "" {
+		t.Errorf("Failed: unexpected invalid serial numbers %+v", result.Invalid)
+	}
+	if len(progress) != 1 || progress[0] != (ImportProgress{Done: 5, Total: 12, Batches: 1}) {
+		t.Errorf("Failed: unexpected progress %+v", progress)
+	}
+
+	progress = nil
+	opts.Checkpoint = result.Checkpoint
+	opts.Progress = func(p ImportProgress) { progress = append(progress, p) }
+	result, err = client.ImportSerialNumbers(context.Background(), model, strings.NewReader(csvFile.String()), opts)
+	if err != nil || result.Imported != 7 || result.Checkpoint != 12 {
+		t.Errorf("Failed: unexpected resumed import %+v %v", result, err)
+	}
+	if len(progress) != 2 || progress[1] != (ImportProgress{Done: 12, Total: 12, Batches: 2}) {
+		t.Errorf("Failed: unexpected progress %+v", progress)
+	}
+
+	ndjson := "{\"sn\": \"sn100\", \"lot\": 1}\n\n{\"sn\": 101}\n{\"lot\": 2}\n"
+	result, err = client.ImportSerialNumbers(context.Background(), model, strings.NewReader(ndjson), ImportOptions{Format: ImportNDJSON})
+	if err != nil || result.Imported != 2 || len(result.Invalid) != 1 || result.Invalid[0].Line != 4 {
+		t.Errorf("Failed: unexpected NDJSON import %+v %v", result, err)
+	}
+
+	_, err = client.ImportSerialNumbers(context.Background(), model, strings.NewReader("{\"sn\": 1}\n\n{\"sn\": "), ImportOptions{Format: ImportNDJSON})
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("Failed: expected malformed NDJSON to fail at line 3, got %v", err)
+	}
+
+	var exported bytes.Buffer
+	n, err := client.ExportSerialNumbers(context.Background(), model, &exported)
+	if err != nil || n != 14 {
+		t.Errorf("Failed: exported %d serial numbers: %v", n, err)
+	}
+	lines := strings.Split(strings.TrimSpace(exported.String()), "\n")
+	if len(lines) != 15 || lines[0] != "sn,rid,extra" || lines[1] != "sn000,," || lines[14] != "101,," {
+		t.Errorf("Failed: unexpected export %q", exported.String())
+	}
+
+	// extra fields are ignored, with or without header
+	for _, test := range []struct {
+		file string
+		opts ImportOptions
+	}{
+		{"batch,sn,note\nb1,sn200,first run\n", ImportOptions{}},
+		{"sn201,lot 1\n", ImportOptions{}},
+		{"lot 2,sn202\n", ImportOptions{SNColumn: 1}},
+	} {
+		result, err := client.ImportSerialNumbers(context.Background(), model, strings.NewReader(test.file), test.opts)
+		if err != nil || result.Imported != 1 || len(result.Invalid) != 0 {
+			t.Errorf("Failed: unexpected import of %q %+v %v", test.file, result, err)
+		}
+	}
+	if entries, err := Serialnumber_list(ProvModel{}, vendortoken, model, 14, 10); err != nil ||
+		len(entries) != 3 || entries[0].SN != "sn200" || entries[1].SN != "sn201" || entries[2].SN != "sn202" {
+		t.Errorf("Failed: unexpected serial numbers %+v %v", entries, err)
+	}
+
+	// an export imports back as is
+	result, err = client.ImportSerialNumbers(context.Background(), model+"x", &exported, ImportOptions{Checkpoint: 14})
+	if err != nil || result.Checkpoint != 14 || result.Imported != 0 || len(result.Invalid) != 0 {
+		t.Errorf("Failed: unexpected import of export %+v %v", result, err)
+	}
+}
+
+func TestValidateSerialNumber(t *testing.T) {
+	for _, sn := range []string{"", "a b", "a,b", "a\tb", strings.Repeat("x", MaxSerialNumberLength+1)} {
+		if ValidateSerialNumber(sn) == nil {
+			t.Errorf("Failed: %q should be invalid", sn)
+		}
+	}
+	for _, sn := range []string{"001", "AB-12_3.x", "äöü", strings.Repeat("x", MaxSerialNumberLength)} {
+		if err := ValidateSerialNumber(sn); err != nil {
+			t.Errorf("Failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package goonep
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// DefaultSNPageSize is the number of serial numbers an SNIterator fetches
+// per request unless told otherwise
+var DefaultSNPageSize = 1000
+
+// SNIterator pages through the serial numbers of a model:
+//
+//	it := goonep.SerialNumbers(ctx, "mymodel")
+//	it.Status = "activated"
+//	for it.Next() {
+//		entry := it.Entry()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+//
+// PageSize and Status must be set before the first call to Next.
+type SNIterator struct {
+	// PageSize is the number of serial numbers fetched per request. Zero
+	// means DefaultSNPageSize.
+	PageSize int
+
+	// Status, when set, restricts the iteration to serial numbers with
+	// that status: "unused", "notactivated", "activated" or "disabled".
+	// Entries then carry it as their Status.
+	Status string
+
+	prov  ProvModel
+	ctx   context.Context
+	model string
+
+	offset int
+	page   []SerialNumberEntry
+	entry  SerialNumberEntry
+	last   bool
+	err    error
+}
+
+// SerialNumbers returns an iterator over the serial numbers of model
+func (c *Client) SerialNumbers(ctx context.Context, model string) *SNIterator {
+	return c.provModel().serialNumbers(ctx, model)
+}
+
+// serialNumbers is SerialNumbers authenticated the way m is managed
+func (m ProvModel) serialNumbers(ctx context.Context, model string) *SNIterator {
+	return &SNIterator{prov: m, ctx: ctx, model: model}
+}
+
+// Next advances to the next serial number, fetching another page when
+// needed. It returns false when there are no more or an error occurred.
+func (it *SNIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	for len(it.page) == 0 {
+		if it.last {
+			return false
+		}
+		if err := it.fetch(); err != nil {
+			it.err = err
+			return false
+		}
+	}
+	it.entry, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+// Entry returns the serial number Next advanced to
+func (it *SNIterator) Entry() SerialNumberEntry {
+	return it.entry
+}
+
+// Err returns the error that ended the iteration, if any
+func (it *SNIterator) Err() error {
+	return it.err
+}
+
+// fetch gets the page at it.offset
+func (it *SNIterator) fetch() error {
+	if err := it.ctx.Err(); err != nil {
+		return err
+	}
+	limit := it.PageSize
+	if limit <= 0 {
+		limit = DefaultSNPageSize
+	}
+
+	var params = url.Values{}
+	params.Set("offset", strconv.Itoa(it.offset))
+	params.Set("limit", strconv.Itoa(limit))
+	if it.Status != "" {
+		params.Set("status", it.Status)
+	}
+	var path = PROVISION_MANAGE_MODEL + url.PathEscape(it.model) + "/?" + params.Encode()
+	body, err := it.prov.request(it.ctx, path, "", "", "GET", http.Header{})
+	if err != nil {
+		return err
+	}
+	page, err := ParseSerialNumberList(body)
+	if err != nil {
+		return err
+	}
+	for i := range page {
+		page[i].Status = it.Status
+	}
+
+	it.offset += len(page)
+	it.last = len(page) < limit
+	it.page = page
+	return nil
+}
+
+// SerialNumbers calls Client.SerialNumbers on the default client
+func SerialNumbers(ctx context.Context, model string) *SNIterator {
+	return defaultClient().SerialNumbers(ctx, model)
+}
@@ -0,0 +1,66 @@
+package goonep
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func TestSerialNumbers(t *testing.T) {
+	setupProvision(t)
+	ctx := context.Background()
+	var model = "MyPagedModel" + strconv.Itoa(rand.Intn(10000000))
+
+	resp, _ := Lookup(clonecik, "alias", "")
+	if err := Model_create(ProvModel{}, vendortoken, model, resp.Results[0].Body.(string), true, true, true); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	defer Model_remove(ProvModel{}, vendortoken, model)
+
+	var sns []string
+	for i := 0; i < 25; i++ {
+		sns = append(sns, fmt.Sprintf("sn%03d", i))
+	}
+	if err := Serialnumber_add_batch(ProvModel{}, vendortoken, model, sns); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	portal, _ := Lookup(portalcik, "alias", "")
+	for _, sn := range sns[:3] {
+		if err := Serialnumber_enable(ProvModel{}, vendortoken, model, sn, portal.Results[0].Body.(string)); err != nil {
+			t.Fatalf("Failed: %v", err)
+		}
+	}
+
+	client := *DefaultClient
+	client.VendorToken = vendortoken
+
+	it := client.SerialNumbers(ctx, model)
+	it.PageSize = 10
+	var got []string
+	for it.Next() {
+		got = append(got, it.Entry().SN)
+	}
+	if err := it.Err(); err != nil {
+		t.Errorf("Failed: %v", err)
+	}
+	if len(got) != 25 || got[0] != "sn000" || got[24] != "sn024" {
+		t.Errorf("Failed: iterated over %v", got)
+	}
+
+	it = client.SerialNumbers(ctx, model)
+	it.Status = "notactivated"
+	var enabled []SerialNumberEntry
+	for it.Next() {
+		enabled = append(enabled, it.Entry())
+	}
+	if it.Err() != nil || len(enabled) != 3 || enabled[0].Status != "notactivated" || !validCikRid(enabled[0].Rid) {
+		t.Errorf("Failed: unexpected entries %+v %v", enabled, it.Err())
+	}
+
+	it = client.SerialNumbers(ctx, "missing"+model)
+	if it.Next() || !IsNotFound(it.Err()) {
+		t.Errorf("Failed: expected not found, got %v", it.Err())
+	}
+}
@@ -0,0 +1,482 @@
+package goonep
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// DeviceSpec is the desired set of resources of a device client. Resources
+// are identified by their alias in the client. Specs are written in JSON
+//
+//	{
+//		"dataports": [
+//			{"alias": "temperature", "format": "float", "retention": {"count": 1000}}
+//		],
+//		"scripts": [
+//			{"alias": "convert", "file": "convert.lua"}
+//		],
+//		"dispatches": [
+//			{"alias": "alert", "method": "email", "recipient": "ops@example.com",
+//			 "subject": "too hot", "subscribe": "temperature"}
+//		]
+//	}
+//
+// or in YAML with the same fields:
+//
+//	dataports:
+//	  - alias: temperature
+//	    format: float
+//	    retention: {count: 1000}
+//	scripts:
+//	  - alias: convert
+//	    file: convert.lua
+type DeviceSpec struct {
+	Dataports  []DataportSpec `json:"dataports,omitempty"`
+	Scripts    []ScriptSpec   `json:"scripts,omitempty"`
+	Dispatches []DispatchSpec `json:"dispatches,omitempty"`
+}
+
+// DataportSpec is a dataport of a DeviceSpec. Name defaults to the alias.
+type DataportSpec struct {
+	Alias     string    `json:"alias"`
+	Name      string    `json:"name,omitempty"`
+	Format    string    `json:"format"` // "float", "integer" or "string"
+	Meta      string    `json:"meta,omitempty"`
+	Public    bool      `json:"public,omitempty"`
+	Retention Retention `json:"retention"`
+}
+
+// ScriptSpec is a Lua script datarule of a DeviceSpec. The source is
+// either given as Script or read from File, relative to the spec file.
+type ScriptSpec struct {
+	Alias     string    `json:"alias"`
+	Name      string    `json:"name,omitempty"`
+	Script    string    `json:"script,omitempty"`
+	File      string    `json:"file,omitempty"`
+	Meta      string    `json:"meta,omitempty"`
+	Retention Retention `json:"retention"`
+}
+
+// DispatchSpec is a dispatch of a DeviceSpec. Subscribe is the alias of
+// the resource it is triggered by.
+type DispatchSpec struct {
+	Alias     string    `json:"alias"`
+	Name      string    `json:"name,omitempty"`
+	Method    string    `json:"method"`
+	Recipient string    `json:"recipient"`
+	Subject   string    `json:"subject,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	Subscribe string    `json:"subscribe,omitempty"`
+	Meta      string    `json:"meta,omitempty"`
+	Retention Retention `json:"retention"`
+}
+
+// LoadDeviceSpec reads the spec in the file at path, in YAML when the file
+// name ends in .yaml or .yml and in JSON otherwise
+func LoadDeviceSpec(path string) (*DeviceSpec, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return ParseDeviceSpecYAML(f, filepath.Dir(path))
+	}
+	return ParseDeviceSpec(f, filepath.Dir(path))
+}
+
+// ParseDeviceSpec reads a JSON spec from r and checks it. Script files are
+// read relative to dir.
+func ParseDeviceSpec(r io.Reader, dir string) (*DeviceSpec, error) {
+	var spec DeviceSpec
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("goonep: reading device spec: %w", err)
+	}
+	for i, script := range spec.Scripts {
+		if script.File == "" {
+			continue
+		}
+		if script.Script != "" {
+			return nil, fmt.Errorf("goonep: script %s has both script and file", script.Alias)
+		}
+		path := script.File
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		source, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		spec.Scripts[i].Script = string(source)
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return &spec, nil
+}
+
+// ParseDeviceSpecYAML is like ParseDeviceSpec but reads the spec in YAML.
+// Block and flow collections, quoted and block scalars are understood;
+// anchors, aliases and tags are not.
+func ParseDeviceSpecYAML(r io.Reader, dir string) (*DeviceSpec, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	v, err := decodeYAML(data)
+	if err != nil {
+		return nil, fmt.Errorf("goonep: reading device spec: %w", err)
+	}
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("goonep: reading device spec: %w", err)
+	}
+	return ParseDeviceSpec(bytes.NewReader(buf), dir)
+}
+
+// Validate checks that every resource has a unique alias and what its type
+// needs
+func (s *DeviceSpec) Validate() error {
+	aliases := map[string]bool{}
+	check := func(alias string) error {
+		if alias == "" {
+			return fmt.Errorf("goonep: device spec has a resource without alias")
+		}
+		if aliases[alias] {
+			return fmt.Errorf("goonep: device spec has alias %s twice", alias)
+		}
+		aliases[alias] = true
+		return nil
+	}
+	for _, dataport := range s.Dataports {
+		if err := check(dataport.Alias); err != nil {
+			return err
+		}
+		switch dataport.Format {
+		case "float", "integer", "string":
+		default:
+			return fmt.Errorf("goonep: dataport %s has format %q", dataport.Alias, dataport.Format)
+		}
+	}
+	for _, script := range s.Scripts {
+		if err := check(script.Alias); err != nil {
+			return err
+		}
+		if script.Script == "" {
+			return fmt.Errorf("goonep: script %s has no source", script.Alias)
+		}
+	}
+	for _, dispatch := range s.Dispatches {
+		if err := check(dispatch.Alias); err != nil {
+			return err
+		}
+		if dispatch.Method == "" {
+			return fmt.Errorf("goonep: dispatch %s has no method", dispatch.Alias)
+		}
+	}
+	for _, dispatch := range s.Dispatches {
+		if dispatch.Subscribe != "" && !aliases[dispatch.Subscribe] {
+			return fmt.Errorf("goonep: dispatch %s subscribes to unknown %s", dispatch.Alias, dispatch.Subscribe)
+		}
+	}
+	return nil
+}
+
+// ChangeAction is what a Change does to a resource
+type ChangeAction string
+
+const (
+	CreateResource ChangeAction = "create"
+	UpdateResource ChangeAction = "update"
+	DropResource   ChangeAction = "drop"
+)
+
+// Change is one step of a DevicePlan
+type Change struct {
+	Action ChangeAction
+	Type   string // "dataport", "datarule" or "dispatch"
+	Alias  string
+
+	// RID is the resource updated or dropped
+	RID string
+
+	// Desc is the description to create the resource with, or the fields
+	// to update
+	Desc map[string]interface{}
+
+	// Fields are the names of the fields an update changes
+	Fields []string
+
+	// Subscribe is the alias of the resource a dispatch subscribes to. It
+	// is resolved when the plan is applied, as it may not exist before.
+	Subscribe string
+}
+
+func (c Change) String() string {
+	switch c.Action {
+	case CreateResource:
+		return fmt.Sprintf("+ %s %s", c.Type, c.Alias)
+	case UpdateResource:
+		return fmt.Sprintf("~ %s %s: %s", c.Type, c.Alias, strings.Join(c.Fields, ", "))
+	}
+	return fmt.Sprintf("- %s %s (%s)", c.Type, c.Alias, c.RID)
+}
+
+// DevicePlan is the changes that bring a client in line with a DeviceSpec, as
+// returned by Plan. Creations come first, dispatches last so the resources
+// they subscribe to exist, then updates and drops.
+type DevicePlan struct {
+	Changes []Change
+
+	// aliases are the RIDs of the client's resources by alias
+	aliases map[string]string
+}
+
+// Empty reports whether the client already matches the spec, leaving drops
+// aside when drop is false
+func (p *DevicePlan) Empty(drop bool) bool {
+	for _, change := range p.Changes {
+		if change.Action != DropResource || drop {
+			return false
+		}
+	}
+	return true
+}
+
+// String lists the changes one per line
+func (p *DevicePlan) String() string {
+	var b strings.Builder
+	for _, change := range p.Changes {
+		b.WriteString(change.String())
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// ApplyOptions tune ApplyWithOptions
+type ApplyOptions struct {
+	// Drop drops the aliased resources the spec does not name. Without it
+	// drops are left out.
+	Drop bool
+
+	// DryRun prints the changes to Output instead of making them. Nil
+	// Output means os.Stdout.
+	DryRun bool
+	Output io.Writer
+}
+
+// descMap turns a description into the map the server reports it as
+func descMap(desc interface{}) map[string]interface{} {
+	buf, _ := json.Marshal(desc)
+	var m map[string]interface{}
+	json.Unmarshal(buf, &m)
+	return m
+}
+
+// desired returns the type, description and subscription of each resource
+// of the spec, in the order they are created
+func (s *DeviceSpec) desired() []Change {
+	var changes []Change
+	for _, d := range s.Dataports {
+		desc := DataportDesc{Format: d.Format, Meta: d.Meta, Name: d.Name, Public: d.Public, Retention: d.Retention}
+		if desc.Name == "" {
+			desc.Name = d.Alias
+		}
+		changes = append(changes, Change{Type: "dataport", Alias: d.Alias, Desc: descMap(desc)})
+	}
+	for _, d := range s.Scripts {
+		desc := DataruleDesc{Format: "string", Meta: d.Meta, Name: d.Name, Retention: d.Retention, Rule: Rule{Script: d.Script}}
+		if desc.Name == "" {
+			desc.Name = d.Alias
+		}
+		changes = append(changes, Change{Type: "datarule", Alias: d.Alias, Desc: descMap(desc)})
+	}
+	for _, d := range s.Dispatches {
+		desc := DispatchDesc{Message: d.Message, Meta: d.Meta, Method: d.Method, Name: d.Name, Recipient: d.Recipient, Retention: d.Retention, Subject: d.Subject}
+		if desc.Name == "" {
+			desc.Name = d.Alias
+		}
+		changes = append(changes, Change{Type: "dispatch", Alias: d.Alias, Desc: descMap(desc), Subscribe: d.Subscribe})
+	}
+	return changes
+}
+
+// Plan compares the resources of the client auth belongs to with spec and
+// returns the changes that would make them match. The resources are found
+// with Walk, which lists them and looks up their aliases and info.
+// Resources without an alias are left alone.
+func (c *Client) Plan(ctx context.Context, auth interface{}, spec *DeviceSpec) (*DevicePlan, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+
+	plan := &DevicePlan{aliases: map[string]string{}}
+	existing := map[string]Node{}
+	var unknown []Node
+	wanted := map[string]bool{}
+	for _, change := range spec.desired() {
+		wanted[change.Alias] = true
+	}
+	opts := WalkOptions{MaxDepth: 1, Types: resourceTypes[1:]}
+	err := c.Walk(ctx, auth, "", func(node Node) error {
+		keep := false
+		for _, alias := range node.Aliases {
+			plan.aliases[alias] = node.RID
+			existing[alias] = node
+			keep = keep || wanted[alias]
+		}
+		// a resource is dropped once, and only when none of its aliases
+		// is in the spec
+		if !keep && len(node.Aliases) > 0 {
+			unknown = append(unknown, node)
+		}
+		return nil
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var updates []Change
+	for _, change := range spec.desired() {
+		node, ok := existing[change.Alias]
+		if !ok {
+			change.Action = CreateResource
+			plan.Changes = append(plan.Changes, change)
+			continue
+		}
+		if node.Type != change.Type {
+			return nil, fmt.Errorf("goonep: %s is a %s, the spec wants a %s", change.Alias, node.Type, change.Type)
+		}
+
+		update := Change{Action: UpdateResource, Type: change.Type, Alias: change.Alias, RID: node.RID, Desc: map[string]interface{}{}}
+		current := descMap(node.Description)
+		for field, value := range change.Desc {
+			if reflect.DeepEqual(current[field], value) {
+				continue
+			}
+			if field == "format" {
+				return nil, fmt.Errorf("goonep: %s has format %v, the spec wants %v", change.Alias, current[field], value)
+			}
+			update.Desc[field] = value
+			update.Fields = append(update.Fields, field)
+		}
+		if change.Subscribe != "" && plan.aliases[change.Subscribe] != current["subscribe"] {
+			update.Subscribe = change.Subscribe
+			update.Fields = append(update.Fields, "subscribe")
+		}
+		if len(update.Fields) > 0 {
+			sort.Strings(update.Fields)
+			updates = append(updates, update)
+		}
+	}
+	plan.Changes = append(plan.Changes, updates...)
+
+	for _, node := range unknown {
+		plan.Changes = append(plan.Changes, Change{Action: DropResource, Type: node.Type, Alias: node.Aliases[0], RID: node.RID})
+	}
+	return plan, nil
+}
+
+// Apply makes the changes of plan to the client auth belongs to, leaving
+// out drops
+func (c *Client) Apply(ctx context.Context, auth interface{}, plan *DevicePlan) error {
+	return c.ApplyWithOptions(ctx, auth, plan, ApplyOptions{})
+}
+
+// ApplyWithOptions is like Apply but can drop resources and print the
+// changes instead of making them
+func (c *Client) ApplyWithOptions(ctx context.Context, auth interface{}, plan *DevicePlan, opts ApplyOptions) error {
+	if opts.DryRun {
+		out := opts.Output
+		if out == nil {
+			out = os.Stdout
+		}
+		for _, change := range plan.Changes {
+			if change.Action == DropResource && !opts.Drop {
+				continue
+			}
+			if _, err := fmt.Fprintln(out, change); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	aliases := map[string]string{}
+	for alias, rid := range plan.aliases {
+		aliases[alias] = rid
+	}
+	for _, change := range plan.Changes {
+		desc := map[string]interface{}{}
+		for field, value := range change.Desc {
+			desc[field] = value
+		}
+		if change.Subscribe != "" {
+			rid, ok := aliases[change.Subscribe]
+			if !ok {
+				resp, err := c.LookupContext(ctx, auth, "alias", change.Subscribe)
+				if err != nil {
+					return fmt.Errorf("goonep: looking up %s for %s: %w", change.Subscribe, change.Alias, err)
+				}
+				if rid, err = resp.Results[0].RID(); err != nil {
+					return err
+				}
+			}
+			desc["subscribe"] = rid
+		}
+
+		switch change.Action {
+		case CreateResource:
+			resp, err := c.CreateContext(ctx, auth, change.Type, desc)
+			if err != nil {
+				return fmt.Errorf("goonep: creating %s: %w", change.Alias, err)
+			}
+			rid, err := resp.Results[0].RID()
+			if err != nil {
+				return err
+			}
+			if _, err := c.OneMapContext(ctx, auth, rid, change.Alias); err != nil {
+				return fmt.Errorf("goonep: mapping %s: %w", change.Alias, err)
+			}
+			aliases[change.Alias] = rid
+		case UpdateResource:
+			if _, err := c.UpdateContext(ctx, auth, change.RID, desc); err != nil {
+				return fmt.Errorf("goonep: updating %s: %w", change.Alias, err)
+			}
+		case DropResource:
+			if !opts.Drop {
+				continue
+			}
+			if _, err := c.DropContext(ctx, auth, change.RID); err != nil {
+				return fmt.Errorf("goonep: dropping %s: %w", change.Alias, err)
+			}
+		}
+	}
+	return nil
+}
+
+// the package level functions below call their Client counterparts on the
+// default client
+
+func Plan(ctx context.Context, auth interface{}, spec *DeviceSpec) (*DevicePlan, error) {
+	return defaultClient().Plan(ctx, auth, spec)
+}
+
+func Apply(ctx context.Context, auth interface{}, plan *DevicePlan) error {
+	return defaultClient().Apply(ctx, auth, plan)
+}
+
+func ApplyWithOptions(ctx context.Context, auth interface{}, plan *DevicePlan, opts ApplyOptions) error {
+	return defaultClient().ApplyWithOptions(ctx, auth, plan, opts)
+}
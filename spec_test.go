@@ -0,0 +1,223 @@
+package goonep
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testSpec = `{
+	"dataports": [
+		{"alias": "temp", "format": "float", "retention": {"count": 100}},
+		{"alias": "status", "format": "string"}
+	],
+	"scripts": [
+		{"alias": "convert", "file": "convert.lua"}
+	],
+	"dispatches": [
+		{"alias": "alert", "method": "email", "recipient": "ops@example.com", "subscribe": "status"}
+	]
+}`
+
+const testSpecYAML = `# the same spec as testSpec
+dataports:
+  - alias: temp
+    format: float
+    retention: {count: 100}
+  - {alias: status, format: string}
+scripts:
+- alias: convert
+  file: convert.lua
+dispatches:
+  - alias: alert
+    method: email
+    recipient: "ops@example.com"
+    subscribe: status # the alias, not the RID
+`
+
+func TestPlanApply(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "device.json")
+	if err := os.WriteFile(path, []byte(testSpec), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "convert.lua"), []byte("-- convert"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	spec, err := LoadDeviceSpec(path)
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if spec.Scripts[0].Script != "-- convert" {
+		t.Errorf("Failed: script was not read: %+v", spec.Scripts[0])
+	}
+
+	cik := genCik()
+	plan, err := Plan(ctx, cik, spec)
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	expected := "+ dataport temp\n+ dataport status\n+ datarule convert\n+ dispatch alert\n"
+	if plan.String() != expected {
+		t.Errorf("Failed: unexpected plan %q", plan.String())
+	}
+
+	// a dry run changes nothing
+	var out bytes.Buffer
+	if err := ApplyWithOptions(ctx, cik, plan, ApplyOptions{DryRun: true, Output: &out}); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if out.String() != expected {
+		t.Errorf("Failed: unexpected dry run output %q", out.String())
+	}
+	if again, err := Plan(ctx, cik, spec); err != nil || len(again.Changes) != 4 {
+		t.Errorf("Failed: dry run made changes: %v %v", again, err)
+	}
+
+	if err := Apply(ctx, cik, plan); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	plan, err = Plan(ctx, cik, spec)
+	if err != nil || !plan.Empty(true) {
+		t.Fatalf("Failed: expected an empty plan, got %v: %v", plan, err)
+	}
+	resp, _ := Lookup(cik, "alias", "status")
+	status, _ := resp.Results[0].RID()
+	resp, _ = Lookup(cik, "alias", "alert")
+	alert, _ := resp.Results[0].RID()
+	resp, err = Info(cik, alert, map[string]interface{}{"description": true})
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	info, _ := resp.Results[0].Info()
+	if info.Description["subscribe"] != status || info.Description["recipient"] != "ops@example.com" {
+		t.Errorf("Failed: unexpected dispatch %+v", info.Description)
+	}
+
+	// change a retention and a script, and add a resource the spec lacks
+	spec.Dataports[0].Retention.Count = 10
+	spec.Scripts[0].Script = "-- convert v2"
+	resp, _ = CreateDataport(cik, DataportDesc{Format: "integer", Name: "old"})
+	old, _ := resp.Results[0].RID()
+	if _, err := OneMap(cik, old, "old"); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	plan, err = Plan(ctx, cik, spec)
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	var lines []string
+	for _, change := range plan.Changes {
+		lines = append(lines, change.String())
+	}
+	expectedLines := []string{"~ dataport temp: retention", "~ datarule convert: rule", "- dataport old (" + old + ")"}
+	if !reflect.DeepEqual(lines, expectedLines) {
+		t.Errorf("Failed: unexpected plan %v", lines)
+	}
+	if plan.Empty(false) {
+		t.Errorf("Failed: expected updates")
+	}
+
+	if err := Apply(ctx, cik, plan); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	plan, err = Plan(ctx, cik, spec)
+	if err != nil || !plan.Empty(false) || plan.Empty(true) {
+		t.Errorf("Failed: expected only the drop to be left, got %v: %v", plan, err)
+	}
+	if err := ApplyWithOptions(ctx, cik, plan, ApplyOptions{Drop: true}); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if plan, err = Plan(ctx, cik, spec); err != nil || !plan.Empty(true) {
+		t.Errorf("Failed: expected an empty plan, got %v: %v", plan, err)
+	}
+
+	// formats cannot be changed
+	spec.Dataports[1].Format = "integer"
+	if _, err := Plan(ctx, cik, spec); err == nil {
+		t.Errorf("Failed: expected a format change to fail")
+	}
+}
+
+func TestParseDeviceSpec(t *testing.T) {
+	invalid := []string{
+		`{"dataports": [{"alias": "a", "format": "bool"}]}`,
+		`{"dataports": [{"alias": "a", "format": "float"}, {"alias": "a", "format": "float"}]}`,
+		`{"scripts": [{"alias": "s"}]}`,
+		`{"dispatches": [{"alias": "d", "method": "email", "subscribe": "missing"}]}`,
+		`{"dataport": []}`,
+	}
+	for _, spec := range invalid {
+		if _, err := ParseDeviceSpec(strings.NewReader(spec), "."); err == nil {
+			t.Errorf("Failed: expected %s to be invalid", spec)
+		}
+	}
+	invalidYAML := []string{
+		"dataports:\n  - alias: a\n    format: bool\n",
+		"dataports:\n  - alias: a\n    format: float\n    colour: red\n",
+		"dataports:\n  - alias: a\n     format: float\n",
+		"dataports: [{alias: a, format: float}\n",
+	}
+	for _, spec := range invalidYAML {
+		if _, err := ParseDeviceSpecYAML(strings.NewReader(spec), "."); err == nil {
+			t.Errorf("Failed: expected %q to be invalid", spec)
+		}
+	}
+}
+
+func TestLoadDeviceSpecYAML(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"device.json": testSpec, "device.yaml": testSpecYAML, "convert.lua": "-- convert"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed: %v", err)
+		}
+	}
+	fromJSON, err := LoadDeviceSpec(filepath.Join(dir, "device.json"))
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	fromYAML, err := LoadDeviceSpec(filepath.Join(dir, "device.yaml"))
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if !reflect.DeepEqual(fromJSON, fromYAML) {
+		t.Errorf("Failed: YAML spec %+v differs from JSON spec %+v", fromYAML, fromJSON)
+	}
+}
+
+func TestPlanAliases(t *testing.T) {
+	ctx := context.Background()
+	cik := genCik()
+	spec := &DeviceSpec{Dataports: []DataportSpec{{Alias: "temperature", Format: "float"}}}
+
+	// temperature is also known by an old alias, and one resource has two
+	// aliases the spec does not name
+	resp, _ := CreateDataport(cik, DataportDesc{Format: "float", Name: "temperature"})
+	temp, _ := resp.Results[0].RID()
+	resp, _ = CreateDataport(cik, DataportDesc{Format: "integer", Name: "old"})
+	old, _ := resp.Results[0].RID()
+	for alias, rid := range map[string]string{"temperature": temp, "temp_old": temp, "old": old, "older": old} {
+		if _, err := OneMap(cik, rid, alias); err != nil {
+			t.Fatalf("Failed: %v", err)
+		}
+	}
+
+	plan, err := Plan(ctx, cik, spec)
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if len(plan.Changes) != 1 || plan.Changes[0].Action != DropResource || plan.Changes[0].RID != old {
+		t.Errorf("Failed: unexpected plan %v", plan)
+	}
+	if err := ApplyWithOptions(ctx, cik, plan, ApplyOptions{Drop: true}); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if plan, err := Plan(ctx, cik, spec); err != nil || !plan.Empty(true) {
+		t.Errorf("Failed: expected an empty plan, got %v: %v", plan, err)
+	}
+}
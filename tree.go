@@ -0,0 +1,350 @@
+package goonep
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"time"
+)
+
+// TreeFormatVersion is the version of the documents ExportTree writes
+const TreeFormatVersion = 1
+
+// DefaultHistoryLimit is the number of data points ExportTree exports per
+// dataport unless told otherwise
+var DefaultHistoryLimit = 100000
+
+// TreeDocument is a resource and everything below it, as written by
+// ExportTree
+type TreeDocument struct {
+	Version int `json:"version"`
+
+	// Exported is the Unix time of the export
+	Exported int64 `json:"exported"`
+
+	Root *TreeResource `json:"root"`
+}
+
+// TreeResource is one resource of a TreeDocument. RID is the resource's id
+// at the time of the export; ImportTree replaces it where a description
+// refers to it: in subscribe and in the script of a datarule.
+type TreeResource struct {
+	RID         string                 `json:"rid"`
+	Type        string                 `json:"type"`
+	Aliases     []string               `json:"aliases,omitempty"`
+	Description map[string]interface{} `json:"description"`
+	Tags        []string               `json:"tags,omitempty"`
+
+	// Comments are [visibility, text] pairs
+	Comments [][]string `json:"comments,omitempty"`
+
+	// History are the [timestamp, value] data points of a dataport,
+	// oldest first
+	History [][]interface{} `json:"history,omitempty"`
+
+	Children []*TreeResource `json:"children,omitempty"`
+}
+
+// ExportOptions tune ExportTree
+type ExportOptions struct {
+	// History exports the data points of dataports
+	History bool
+
+	// HistoryLimit bounds the number of points exported per dataport,
+	// keeping the newest. Zero means DefaultHistoryLimit.
+	HistoryLimit int
+}
+
+// ExportTree writes rid and every resource below it to w as a JSON
+// TreeDocument.
+func (c *Client) ExportTree(ctx context.Context, auth interface{}, rid string, w io.Writer, opts ExportOptions) error {
+	doc := TreeDocument{Version: TreeFormatVersion, Exported: time.Now().Unix()}
+	resources := map[string]*TreeResource{}
+	var dataports []Node
+	walkOpts := WalkOptions{Info: map[string]interface{}{"tags": true, "comments": true}}
+	err := c.Walk(ctx, auth, rid, func(node Node) error {
+		resource := &TreeResource{
+			RID:         node.RID,
+			Type:        node.Type,
+			Aliases:     node.Aliases,
+			Description: node.Description,
+			Tags:        node.Info.Tags,
+			Comments:    node.Info.Comments,
+		}
+		resources[node.RID] = resource
+		if node.Depth == 0 {
+			doc.Root = resource
+		} else {
+			parent := resources[node.Parent]
+			parent.Children = append(parent.Children, resource)
+		}
+		if opts.History && node.Type == "dataport" {
+			dataports = append(dataports, node)
+		}
+		return nil
+	}, walkOpts)
+	if err != nil {
+		return err
+	}
+
+	limit := opts.HistoryLimit
+	if limit <= 0 {
+		limit = DefaultHistoryLimit
+	}
+	for _, node := range dataports {
+		readAuth := auth
+		if node.Depth > 0 {
+			if readAuth, err = c.walkAuth(auth, node.Parent); err != nil {
+				return err
+			}
+		}
+		resp, err := c.ReadContext(ctx, readAuth, node.RID, map[string]interface{}{"limit": limit, "sort": "desc"})
+		if err != nil {
+			return err
+		}
+		points, err := resp.Results[0].Points()
+		if err != nil {
+			return err
+		}
+		history := make([][]interface{}, len(points))
+		for i, point := range points {
+			history[len(points)-1-i] = []interface{}{point.Timestamp, point.Value}
+		}
+		resources[node.RID].History = history
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(doc)
+}
+
+// treeItem is a resource of an imported document and the RID its owner had
+// in the document
+type treeItem struct {
+	resource *TreeResource
+	parent   string
+}
+
+// ImportTree recreates the resources of a TreeDocument read from r below
+// the client parentRID, or the client auth belongs to when parentRID is
+// empty. It returns the new RID of each resource by its RID in the
+// document, including those created before an error.
+//
+// Resources referring to others in their description are created after
+// them, with the references replaced. A resource that is part of a cycle
+// of references is created first without the references that do not
+// exist yet, never pointing at the exported resources, and updated with
+// them once the rest exist.
+func (c *Client) ImportTree(ctx context.Context, auth interface{}, parentRID string, r io.Reader) (map[string]string, error) {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	var doc TreeDocument
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("goonep: reading tree: %w", err)
+	}
+	if doc.Version != TreeFormatVersion || doc.Root == nil {
+		return nil, fmt.Errorf("goonep: unsupported tree version %d", doc.Version)
+	}
+
+	var items []treeItem
+	var flatten func(resource *TreeResource, parent string)
+	flatten = func(resource *TreeResource, parent string) {
+		items = append(items, treeItem{resource, parent})
+		for _, child := range resource.Children {
+			flatten(child, resource.RID)
+		}
+	}
+	flatten(doc.Root, "")
+
+	known := map[string]bool{}
+	for _, item := range items {
+		known[item.resource.RID] = true
+	}
+	created := map[string]string{}
+	ready := func(item treeItem) bool {
+		return item.parent == "" || created[item.parent] != ""
+	}
+	resolved := func(item treeItem) bool {
+		for _, ref := range treeReferences(item.resource.Description) {
+			if known[ref] && ref != item.resource.RID && created[ref] == "" {
+				return false
+			}
+		}
+		return true
+	}
+	ownerAuth := func(item treeItem) (interface{}, error) {
+		if item.parent == "" {
+			if parentRID == "" {
+				return auth, nil
+			}
+			return c.walkAuth(auth, parentRID)
+		}
+		return c.walkAuth(auth, created[item.parent])
+	}
+	var stale []treeItem
+	importItem := func(item treeItem) error {
+		itemAuth, err := ownerAuth(item)
+		if err != nil {
+			return err
+		}
+		unresolved, err := c.importResource(ctx, itemAuth, item.resource, created, known)
+		if unresolved {
+			stale = append(stale, item)
+		}
+		return err
+	}
+
+	for pending := items; len(pending) > 0; {
+		var rest []treeItem
+		for _, item := range pending {
+			if !ready(item) || !resolved(item) {
+				rest = append(rest, item)
+				continue
+			}
+			if err := importItem(item); err != nil {
+				return created, err
+			}
+		}
+		if len(rest) == len(pending) {
+			// only cycles are left: break one open
+			i := 0
+			for i < len(rest) && !ready(rest[i]) {
+				i++
+			}
+			if i == len(rest) {
+				return created, fmt.Errorf("goonep: tree has resources without owner")
+			}
+			if err := importItem(rest[i]); err != nil {
+				return created, err
+			}
+			rest = append(rest[:i:i], rest[i+1:]...)
+		}
+		pending = rest
+	}
+
+	for _, item := range stale {
+		itemAuth, err := ownerAuth(item)
+		if err != nil {
+			return created, err
+		}
+		desc, _ := remapReferences(item.resource.Description, created, known)
+		if _, err := c.UpdateContext(ctx, itemAuth, created[item.resource.RID], desc); err != nil {
+			return created, fmt.Errorf("goonep: updating references of %s: %w", item.resource.RID, err)
+		}
+	}
+	return created, nil
+}
+
+// importResource creates resource with auth and restores its aliases,
+// comments, tags and history, adding its new RID to created. It reports
+// whether the resource was created without references to resources of
+// known not created yet.
+func (c *Client) importResource(ctx context.Context, auth interface{}, resource *TreeResource, created map[string]string, known map[string]bool) (bool, error) {
+	desc, unresolved := remapReferences(resource.Description, created, known)
+	resp, err := c.CreateContext(ctx, auth, resource.Type, desc)
+	if err != nil {
+		return false, fmt.Errorf("goonep: creating %s %s: %w", resource.Type, resource.RID, err)
+	}
+	rid, err := resp.Results[0].RID()
+	if err != nil {
+		return false, err
+	}
+	created[resource.RID] = rid
+
+	var calls []interface{}
+	for _, alias := range resource.Aliases {
+		calls = append(calls, walkCall(len(calls)+1, "map", "alias", rid, alias))
+	}
+	for _, comment := range resource.Comments {
+		if len(comment) == 2 {
+			calls = append(calls, walkCall(len(calls)+1, "comment", rid, comment[0], comment[1]))
+		}
+	}
+	for _, tag := range resource.Tags {
+		calls = append(calls, walkCall(len(calls)+1, "tag", rid, "add", tag))
+	}
+	if len(calls) > 0 {
+		if _, err := c.CallMultiContext(ctx, auth, calls); err != nil {
+			return unresolved, fmt.Errorf("goonep: restoring aliases, comments and tags of %s: %w", resource.RID, err)
+		}
+	}
+	if len(resource.History) > 0 {
+		if _, err := c.RecordbatchContext(ctx, auth, rid, resource.History); err != nil {
+			return unresolved, fmt.Errorf("goonep: restoring history of %s: %w", resource.RID, err)
+		}
+	}
+	return unresolved, nil
+}
+
+// ridPattern matches an RID standing on its own in a script
+var ridPattern = regexp.MustCompile(`\b[0-9a-f]{40}\b`)
+
+// treeReferences returns the RIDs a description refers to: the one it
+// subscribes to and those written in its datarule script
+func treeReferences(desc map[string]interface{}) []string {
+	var refs []string
+	if subscribe, ok := desc["subscribe"].(string); ok && subscribe != "" {
+		refs = append(refs, subscribe)
+	}
+	if rule, ok := desc["rule"].(map[string]interface{}); ok {
+		if script, ok := rule["script"].(string); ok {
+			refs = append(refs, ridPattern.FindAllString(script, -1)...)
+		}
+	}
+	return refs
+}
+
+// remapReferences returns a copy of a description with the references
+// treeReferences finds to created resources replaced by their new RIDs.
+// References to resources of known that are not created yet are left out,
+// which the second result reports. Everything else, e.g. meta or names, is
+// left as it is.
+func remapReferences(desc map[string]interface{}, created map[string]string, known map[string]bool) (map[string]interface{}, bool) {
+	unresolved := false
+	remap := func(old string) string {
+		if rid, ok := created[old]; ok {
+			return rid
+		}
+		if known[old] {
+			unresolved = true
+			return ""
+		}
+		return old
+	}
+
+	remapped := make(map[string]interface{}, len(desc))
+	for key, value := range desc {
+		remapped[key] = value
+	}
+	if subscribe, ok := desc["subscribe"].(string); ok && subscribe != "" {
+		if rid := remap(subscribe); rid != "" {
+			remapped["subscribe"] = rid
+		} else {
+			delete(remapped, "subscribe")
+		}
+	}
+	if rule, ok := desc["rule"].(map[string]interface{}); ok {
+		if script, ok := rule["script"].(string); ok {
+			remappedRule := make(map[string]interface{}, len(rule))
+			for key, value := range rule {
+				remappedRule[key] = value
+			}
+			remappedRule["script"] = ridPattern.ReplaceAllStringFunc(script, remap)
+			remapped["rule"] = remappedRule
+		}
+	}
+	return remapped, unresolved
+}
+
+// the package level functions below call their Client counterparts on the
+// default client
+
+func ExportTree(ctx context.Context, auth interface{}, rid string, w io.Writer, opts ExportOptions) error {
+	return defaultClient().ExportTree(ctx, auth, rid, w, opts)
+}
+
+func ImportTree(ctx context.Context, auth interface{}, parentRID string, r io.Reader) (map[string]string, error) {
+	return defaultClient().ImportTree(ctx, auth, parentRID, r)
+}
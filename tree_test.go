@@ -0,0 +1,139 @@
+package goonep
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExportImportTree(t *testing.T) {
+	ctx := context.Background()
+	cik, rids := walkTree(t)
+	a := map[string]interface{}{"cik": cik, "client_id": rids["a"]}
+
+	if _, err := Recordbatch(a, rids["temp"], [][]interface{}{{1000, 20.5}, {2000, 21.5}, {3000, 22}}); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if _, err := Call(a, "comment", []interface{}{rids["temp"], "public", "kitchen"}); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if _, err := Call(a, "tag", []interface{}{rids["temp"], "add", "sensor"}); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	// only references are remapped, not RIDs in meta or inside longer hex
+	meta := "was " + rids["alarm"]
+	if _, err := Update(a, rids["temp"], map[string]interface{}{"meta": meta}); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	// a datarule watching temp, and two referring to each other
+	digest := rids["temp"] + "ff"
+	script := "local temp = '" + rids["temp"] + "' -- " + digest
+	resp, err := CreateDatarule(a, DataruleDesc{Format: "string", Name: "watch", Rule: Rule{Script: script}, Subscribe: rids["temp"]})
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	rids["watch"] = resp.Results[0].Body.(string)
+	resp, _ = CreateDatarule(a, DataruleDesc{Format: "string", Name: "ping", Rule: Rule{Script: "-- none"}})
+	rids["ping"] = resp.Results[0].Body.(string)
+	resp, _ = CreateDatarule(a, DataruleDesc{Format: "string", Name: "pong", Rule: Rule{Script: "-- " + rids["ping"]}})
+	rids["pong"] = resp.Results[0].Body.(string)
+	if _, err := Update(a, rids["ping"], map[string]interface{}{"rule": map[string]interface{}{"script": "-- " + rids["pong"]}}); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	var exported bytes.Buffer
+	if err := ExportTree(ctx, cik, rids["a"], &exported, ExportOptions{History: true, HistoryLimit: 2}); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	var doc TreeDocument
+	if err := json.Unmarshal(exported.Bytes(), &doc); err != nil || doc.Version != TreeFormatVersion || doc.Root.RID != rids["a"] {
+		t.Fatalf("Failed: unexpected document %s %v", exported.String(), err)
+	}
+	if !reflect.DeepEqual(doc.Root.Aliases, []string{"a-alias"}) || len(doc.Root.Children) != 6 {
+		t.Errorf("Failed: unexpected root %+v", doc.Root)
+	}
+
+	target := genCik()
+	created, err := ImportTree(ctx, target, "", bytes.NewReader(exported.Bytes()))
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if len(created) != 8 {
+		t.Errorf("Failed: created %v", created)
+	}
+
+	nodes := map[string]Node{}
+	err = Walk(ctx, target, "", func(node Node) error {
+		nodes[node.Name] = node
+		return nil
+	}, WalkOptions{Info: map[string]interface{}{"comments": true, "tags": true}})
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	for _, name := range []string{"a", "c", "temp", "alarm", "mail", "watch", "ping", "pong"} {
+		if nodes[name].RID != created[rids[name]] {
+			t.Errorf("Failed: %s was not imported as %s: %+v", name, created[rids[name]], nodes[name])
+		}
+	}
+	temp := nodes["temp"]
+	if !reflect.DeepEqual(temp.Aliases, []string{"temp-alias"}) || !reflect.DeepEqual(temp.Info.Comments, [][]string{{"public", "kitchen"}}) ||
+		!reflect.DeepEqual(temp.Info.Tags, []string{"sensor"}) || temp.Description["meta"] != meta {
+		t.Errorf("Failed: unexpected dataport %+v %+v", temp, temp.Info)
+	}
+	if !reflect.DeepEqual(nodes["a"].Aliases, []string{"a-alias"}) {
+		t.Errorf("Failed: unexpected root %+v", nodes["a"])
+	}
+
+	resp, err = Read(map[string]interface{}{"cik": target, "client_id": nodes["a"].RID}, temp.RID, map[string]interface{}{"limit": 10, "sort": "asc"})
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	points, _ := resp.Results[0].Points()
+	if len(points) != 2 || points[0].Timestamp != 2000 || points[1].Timestamp != 3000 {
+		t.Errorf("Failed: unexpected history %+v", points)
+	}
+
+	watch := nodes["watch"].Description
+	if watch["subscribe"] != temp.RID || watch["rule"].(map[string]interface{})["script"] != "local temp = '"+temp.RID+"' -- "+digest {
+		t.Errorf("Failed: references were not remapped %+v", watch)
+	}
+	ping := nodes["ping"].Description["rule"].(map[string]interface{})["script"]
+	pong := nodes["pong"].Description["rule"].(map[string]interface{})["script"]
+	if ping != "-- "+nodes["pong"].RID || pong != "-- "+nodes["ping"].RID {
+		t.Errorf("Failed: cycle was not remapped: %v %v", ping, pong)
+	}
+
+	if _, err := ImportTree(ctx, target, "", strings.NewReader(`{"version": 99}`)); err == nil {
+		t.Errorf("Failed: expected unknown version to fail")
+	}
+}
+
+func TestRemapReferences(t *testing.T) {
+	ping := strings.Repeat("1", 40)
+	pong := strings.Repeat("2", 40)
+	external := strings.Repeat("3", 40)
+	known := map[string]bool{ping: true, pong: true}
+	desc := map[string]interface{}{
+		"subscribe": pong,
+		"meta":      pong,
+		"rule":      map[string]interface{}{"script": "-- " + pong + " " + external},
+	}
+
+	// pong does not exist yet: the source resource must not be referred to
+	remapped, unresolved := remapReferences(desc, map[string]string{}, known)
+	if !unresolved || remapped["subscribe"] != nil || remapped["meta"] != pong ||
+		remapped["rule"].(map[string]interface{})["script"] != "--  "+external {
+		t.Errorf("Failed: unexpected description %v %v", remapped, unresolved)
+	}
+
+	remapped, unresolved = remapReferences(desc, map[string]string{pong: "new"}, known)
+	if unresolved || remapped["subscribe"] != "new" || remapped["rule"].(map[string]interface{})["script"] != "-- new "+external {
+		t.Errorf("Failed: unexpected description %v %v", remapped, unresolved)
+	}
+	if desc["subscribe"] != pong {
+		t.Errorf("Failed: description was changed %v", desc)
+	}
+}
@@ -0,0 +1,333 @@
+package goonep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// DefaultWalkParallelism is the number of clients Walk lists at once unless
+// told otherwise
+var DefaultWalkParallelism = 4
+
+// SkipSubtree is returned by a WalkFunc to have Walk skip the resources of
+// the client it was called with. It is not returned by Walk.
+var SkipSubtree = errors.New("goonep: skip this subtree")
+
+// resourceTypes are the types of resources a client owns, in the order Walk
+// visits them
+var resourceTypes = []string{"client", "dataport", "datarule", "dispatch"}
+
+// Node is a resource visited by Walk
+type Node struct {
+	RID  string
+	Type string // "client", "dataport", "datarule" or "dispatch"
+	Name string
+
+	// Aliases are the aliases the owning client maps to the resource
+	Aliases []string
+
+	// Description depends on the type of the resource, see info
+	Description map[string]interface{}
+
+	// Parent is the RID of the client owning the resource. It is empty
+	// for the root.
+	Parent string
+
+	// Depth is 0 for the root, 1 for the resources it owns and so on
+	Depth int
+
+	// Info is the info of the resource, holding what WalkOptions.Info
+	// asked for on top of its basic info and description
+	Info *ResourceInfo
+}
+
+// WalkFunc is called by Walk for each resource. Returning SkipSubtree for a
+// client skips its resources; returning any other error stops the walk.
+type WalkFunc func(node Node) error
+
+// WalkOptions tune Walk
+type WalkOptions struct {
+	// Parallelism is the number of clients listed at once. Zero means
+	// DefaultWalkParallelism.
+	Parallelism int
+
+	// MaxDepth stops the walk at resources MaxDepth levels below the root.
+	// Zero means no limit.
+	MaxDepth int
+
+	// Types restricts the resources passed to the WalkFunc to these types.
+	// Clients are walked through either way. Nil means every type.
+	Types []string
+
+	// Info are further info options for each resource, e.g.
+	// {"tags": true}
+	Info map[string]interface{}
+}
+
+// infoOptions returns the options of the info call describing a resource
+func (o WalkOptions) infoOptions() map[string]interface{} {
+	options := map[string]interface{}{"basic": true, "description": true}
+	for k, v := range o.Info {
+		options[k] = v
+	}
+	return options
+}
+
+func (o WalkOptions) wants(typ string) bool {
+	if o.Types == nil {
+		return true
+	}
+	for _, t := range o.Types {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// Walk visits rootRID and every resource below it, level by level, calling
+// fn for each. Each level is fetched with one listing and one batch of info
+// calls per client, made with auth on behalf of that client; fn is called
+// from the calling goroutine only, in the order the resources were listed.
+// An empty rootRID walks the client auth belongs to.
+func (c *Client) Walk(ctx context.Context, auth interface{}, rootRID string, fn WalkFunc, opts WalkOptions) error {
+	root, err := c.walkRoot(ctx, auth, rootRID, opts.infoOptions())
+	if err != nil {
+		return err
+	}
+	if opts.wants(root.Type) {
+		if err := fn(root); err == SkipSubtree {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	if root.Type != "client" {
+		return nil
+	}
+
+	types := []string{"client"}
+	for _, typ := range resourceTypes[1:] {
+		if opts.wants(typ) {
+			types = append(types, typ)
+		}
+	}
+
+	level := []Node{root}
+	for depth := 1; len(level) > 0 && (opts.MaxDepth <= 0 || depth <= opts.MaxDepth); depth++ {
+		children, err := c.walkLevel(ctx, auth, level, types, opts)
+		if err != nil {
+			return err
+		}
+
+		var next []Node
+		for _, nodes := range children {
+			for _, node := range nodes {
+				if opts.wants(node.Type) {
+					err := fn(node)
+					if err == SkipSubtree {
+						continue
+					}
+					if err != nil {
+						return err
+					}
+				}
+				if node.Type == "client" {
+					next = append(next, node)
+				}
+			}
+		}
+		level = next
+	}
+	return nil
+}
+
+// walkAuth returns auth acting on behalf of the client rid it owns. Auth
+// that is neither a CIK nor a map cannot be given a client_id, so it is
+// refused rather than used for rid unchanged.
+func (c *Client) walkAuth(auth interface{}, rid string) (interface{}, error) {
+	full, ok := c.fullAuth(auth).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("goonep: cannot act on behalf of %s with auth of type %T", rid, c.fullAuth(auth))
+	}
+	delegated := map[string]interface{}{}
+	for k, v := range full {
+		delegated[k] = v
+	}
+	delegated["client_id"] = rid
+	return delegated, nil
+}
+
+// walkRoot describes the resource a walk starts at
+func (c *Client) walkRoot(ctx context.Context, auth interface{}, rootRID string, infoOptions map[string]interface{}) (Node, error) {
+	var self = map[string]interface{}{"alias": ""}
+	var rid interface{} = rootRID
+	if rootRID == "" {
+		rid = self
+	}
+	calls := []interface{}{
+		walkCall(1, "info", rid, infoOptions),
+		walkCall(2, "info", self, map[string]interface{}{"aliases": true}),
+		walkCall(3, "lookup", "alias", ""),
+	}
+	resp, err := c.CallMultiContext(ctx, auth, calls)
+	if err != nil {
+		return Node{}, err
+	}
+	results := resultsByID(resp)
+
+	if rootRID == "" {
+		if rootRID, err = results[3].RID(); err != nil {
+			return Node{}, err
+		}
+	}
+	node, err := walkNode(rootRID, results[1])
+	if err != nil {
+		return Node{}, err
+	}
+	if owner, err := results[2].Info(); err == nil {
+		node.Aliases = owner.Aliases[rootRID]
+	}
+	return node, nil
+}
+
+// walkLevel lists the resources of each client of a level, at most
+// opts.Parallelism clients at once. The result holds the resources of each
+// client in the order of the level.
+func (c *Client) walkLevel(ctx context.Context, auth interface{}, level []Node, types []string, opts WalkOptions) ([][]Node, error) {
+	parallelism := opts.Parallelism
+	if parallelism <= 0 {
+		parallelism = DefaultWalkParallelism
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	children := make([][]Node, len(level))
+	errs := make([]error, len(level))
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i, parent := range level {
+		wg.Add(1)
+		go func(i int, parent Node) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+			defer func() { <-sem }()
+
+			parentAuth, err := c.walkAuth(auth, parent.RID)
+			if err == nil {
+				children[i], err = c.walkChildren(ctx, parentAuth, parent, types, opts.infoOptions())
+			}
+			if errs[i] = err; err != nil {
+				cancel()
+			}
+		}(i, parent)
+	}
+	wg.Wait()
+
+	// report the error that cancelled the others
+	for _, err := range errs {
+		if err != nil && err != context.Canceled {
+			return nil, err
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return children, nil
+}
+
+// walkChildren lists the resources of parent with a listing and an info
+// call for the aliases, then describes them with one info call each. Each
+// step is sent as a single request.
+func (c *Client) walkChildren(ctx context.Context, auth interface{}, parent Node, types []string, infoOptions map[string]interface{}) ([]Node, error) {
+	calls := []interface{}{
+		walkCall(1, "listing", types, map[string]interface{}{}),
+		walkCall(2, "info", map[string]interface{}{"alias": ""}, map[string]interface{}{"aliases": true}),
+	}
+	resp, err := c.CallMultiContext(ctx, auth, calls)
+	if err != nil {
+		return nil, err
+	}
+	results := resultsByID(resp)
+	listing, err := results[1].Listing()
+	if err != nil {
+		return nil, err
+	}
+	owner, err := results[2].Info()
+	if err != nil {
+		return nil, err
+	}
+
+	var rids []string
+	for _, typ := range types {
+		rids = append(rids, listing[typ]...)
+	}
+	if len(rids) == 0 {
+		return nil, nil
+	}
+
+	calls = calls[:0]
+	for i, rid := range rids {
+		calls = append(calls, walkCall(i+1, "info", rid, infoOptions))
+	}
+	resp, err = c.CallMultiContext(ctx, auth, calls)
+	if err != nil {
+		return nil, err
+	}
+	results = resultsByID(resp)
+
+	nodes := make([]Node, 0, len(rids))
+	for i, rid := range rids {
+		node, err := walkNode(rid, results[i+1])
+		if err != nil {
+			return nil, err
+		}
+		node.Aliases = owner.Aliases[rid]
+		node.Parent = parent.RID
+		node.Depth = parent.Depth + 1
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// walkNode describes rid from the result of its info call
+func walkNode(rid string, result Result) (Node, error) {
+	info, err := result.Info()
+	if err != nil {
+		return Node{}, fmt.Errorf("goonep: walking %s: %w", rid, err)
+	}
+	node := Node{RID: rid, Type: info.Basic.Type, Description: info.Description, Info: info}
+	node.Name, _ = info.Description["name"].(string)
+	return node, nil
+}
+
+func walkCall(id int, procedure string, arguments ...interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"id":        id,
+		"procedure": procedure,
+		"arguments": arguments,
+	}
+}
+
+// resultsByID indexes the results of a response by call id
+func resultsByID(resp Response) map[int]Result {
+	results := make(map[int]Result, len(resp.Results))
+	for _, result := range resp.Results {
+		results[result.Id] = result
+	}
+	return results
+}
+
+// Walk calls Client.Walk on the default client
+func Walk(ctx context.Context, auth interface{}, rootRID string, fn WalkFunc, opts WalkOptions) error {
+	return defaultClient().Walk(ctx, auth, rootRID, fn, opts)
+}
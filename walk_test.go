@@ -0,0 +1,125 @@
+package goonep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// walkTree creates a portal owning two clients, a and b, where a owns a
+// dataport, a datarule and a client c owning a dispatch
+func walkTree(t *testing.T) (string, map[string]string) {
+	cik := genCik()
+	rids := map[string]string{}
+	create := func(auth interface{}, name, typ string, desc interface{}) {
+		resp, err := Create(auth, typ, desc)
+		if err != nil {
+			t.Fatalf("Failed: %v", err)
+		}
+		rids[name] = resp.Results[0].Body.(string)
+		if _, err := OneMap(auth, rids[name], name+"-alias"); err != nil {
+			t.Fatalf("Failed: %v", err)
+		}
+	}
+	as := func(name string) interface{} {
+		return map[string]interface{}{"cik": cik, "client_id": rids[name]}
+	}
+
+	create(cik, "a", "client", ClientDesc{Name: "a"})
+	create(cik, "b", "client", ClientDesc{Name: "b"})
+	create(as("a"), "temp", "dataport", DataportDesc{Format: "float", Name: "temp"})
+	create(as("a"), "alarm", "datarule", DataruleDesc{Format: "integer", Name: "alarm"})
+	create(as("a"), "c", "client", ClientDesc{Name: "c"})
+	create(as("c"), "mail", "dispatch", DispatchDesc{Method: "email", Name: "mail"})
+	return cik, rids
+}
+
+func TestWalk(t *testing.T) {
+	ctx := context.Background()
+	cik, rids := walkTree(t)
+
+	var visited []string
+	var nodes = map[string]Node{}
+	err := Walk(ctx, cik, "", func(node Node) error {
+		visited = append(visited, fmt.Sprintf("%d:%s", node.Depth, node.Name))
+		nodes[node.Name] = node
+		return nil
+	}, WalkOptions{Parallelism: 1})
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	expected := []string{"0:", "1:a", "1:b", "2:c", "2:temp", "2:alarm", "3:mail"}
+	if !reflect.DeepEqual(visited, expected) {
+		t.Errorf("Failed: visited %v, expected %v", visited, expected)
+	}
+	temp := nodes["temp"]
+	if temp.RID != rids["temp"] || temp.Type != "dataport" || temp.Parent != rids["a"] ||
+		!reflect.DeepEqual(temp.Aliases, []string{"temp-alias"}) || temp.Description["format"] != "float" {
+		t.Errorf("Failed: unexpected node %+v", temp)
+	}
+	if nodes["mail"].Type != "dispatch" || nodes["mail"].Parent != rids["c"] {
+		t.Errorf("Failed: unexpected node %+v", nodes["mail"])
+	}
+
+	// a subtree, limited in depth
+	visited = nil
+	err = Walk(ctx, cik, rids["a"], func(node Node) error {
+		visited = append(visited, node.Name)
+		if node.Depth == 0 && !reflect.DeepEqual(node.Aliases, []string{"a-alias"}) {
+			t.Errorf("Failed: unexpected root %+v", node)
+		}
+		return nil
+	}, WalkOptions{MaxDepth: 1})
+	if err != nil || !reflect.DeepEqual(visited, []string{"a", "c", "temp", "alarm"}) {
+		t.Errorf("Failed: visited %v: %v", visited, err)
+	}
+
+	// only dispatches, skipping b
+	visited = nil
+	err = Walk(ctx, cik, "", func(node Node) error {
+		visited = append(visited, node.Name)
+		return nil
+	}, WalkOptions{Types: []string{"dispatch"}})
+	if err != nil || !reflect.DeepEqual(visited, []string{"mail"}) {
+		t.Errorf("Failed: visited %v: %v", visited, err)
+	}
+
+	visited = nil
+	err = Walk(ctx, cik, "", func(node Node) error {
+		visited = append(visited, node.Name)
+		if node.Name == "a" {
+			return SkipSubtree
+		}
+		return nil
+	}, WalkOptions{Parallelism: 8})
+	if err != nil || !reflect.DeepEqual(visited, []string{"", "a", "b"}) {
+		t.Errorf("Failed: visited %v: %v", visited, err)
+	}
+
+	stop := errors.New("stop")
+	err = Walk(ctx, cik, "", func(node Node) error {
+		if node.Name == "temp" {
+			return stop
+		}
+		return nil
+	}, WalkOptions{})
+	if err != stop {
+		t.Errorf("Failed: expected the WalkFunc's error, got %v", err)
+	}
+
+	if err := Walk(ctx, genCik(), rids["a"], func(Node) error { return nil }, WalkOptions{}); err == nil {
+		t.Errorf("Failed: expected walking a foreign client to fail")
+	}
+
+	// auth that cannot be given a client_id cannot be walked below the root
+	visited = nil
+	err = Walk(ctx, map[string]string{"cik": cik}, "", func(node Node) error {
+		visited = append(visited, node.Name)
+		return nil
+	}, WalkOptions{})
+	if err == nil || !reflect.DeepEqual(visited, []string{""}) {
+		t.Errorf("Failed: expected walking with delegation-less auth to fail, visited %v: %v", visited, err)
+	}
+}
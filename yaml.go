@@ -0,0 +1,503 @@
+package goonep
+
+// This file holds a small YAML decoder rather than a YAML library: goonep
+// has no dependencies outside the standard library and device specs only
+// need a few constructs. What it reads is turned into the values JSON
+// decodes to, so specs are checked by the same code either way. Anything
+// beyond the subset described on yamlParser is rejected, not guessed at.
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// yamlLine is a line of a YAML document
+type yamlLine struct {
+	num    int
+	indent int
+	text   string // the line without its indentation
+	raw    string
+}
+
+// yamlParser decodes the subset of YAML device specs are written in: block
+// mappings and sequences, plain and quoted scalars, literal and folded
+// block scalars and flow collections on a single line. Anchors, aliases,
+// tags and multiple documents are not supported.
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+var (
+	yamlInt   = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	yamlFloat = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+)
+
+// decodeYAML turns a YAML document into the maps, slices and scalars
+// encoding/json decodes the same document written in JSON into
+func decodeYAML(data []byte) (interface{}, error) {
+	p := &yamlParser{}
+	for i, raw := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		text := strings.TrimLeft(raw, " ")
+		if strings.HasPrefix(text, "\t") && strings.TrimSpace(text) != "" {
+			return nil, fmt.Errorf("line %d: tabs cannot indent", i+1)
+		}
+		p.lines = append(p.lines, yamlLine{num: i + 1, indent: len(raw) - len(text), text: strings.TrimRight(text, " \t"), raw: raw})
+	}
+
+	if line, ok := p.peek(); ok && line.text == "---" {
+		p.pos++
+	}
+	v, err := p.parseBlock(0)
+	if err != nil {
+		return nil, err
+	}
+	if line, ok := p.peek(); ok && line.text == "..." {
+		p.pos++
+	}
+	if line, ok := p.peek(); ok {
+		return nil, p.errorf(line, "unexpected %q", line.text)
+	}
+	return v, nil
+}
+
+func (p *yamlParser) errorf(line yamlLine, format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", line.num, fmt.Sprintf(format, args...))
+}
+
+// peek returns the next line that is neither blank nor a comment
+func (p *yamlParser) peek() (yamlLine, bool) {
+	for ; p.pos < len(p.lines); p.pos++ {
+		if line := p.lines[p.pos]; line.text != "" && line.text[0] != '#' {
+			return line, true
+		}
+	}
+	return yamlLine{}, false
+}
+
+// parseBlock parses the node starting at the next line if it is indented
+// by at least indent. Nothing there is null.
+func (p *yamlParser) parseBlock(indent int) (interface{}, error) {
+	line, ok := p.peek()
+	if !ok || line.indent < indent {
+		return nil, nil
+	}
+	if isYAMLSeqItem(line.text) {
+		return p.parseSeq(line)
+	}
+	if _, _, ok, err := splitYAMLKey(line.text); err != nil {
+		return nil, p.errorf(line, "%v", err)
+	} else if ok {
+		return p.parseMap(line)
+	}
+	p.pos++
+	if isYAMLBlockHeader(line.text) {
+		return p.blockScalar(yamlLine{indent: line.indent - 1}, line.text)
+	}
+	v, err := parseYAMLScalar(line.text)
+	if err != nil {
+		return nil, p.errorf(line, "%v", err)
+	}
+	return v, nil
+}
+
+// parseSeq parses the block sequence whose first item is on first
+func (p *yamlParser) parseSeq(first yamlLine) ([]interface{}, error) {
+	seq := []interface{}{}
+	for {
+		line, ok := p.peek()
+		if !ok || line.indent < first.indent {
+			break
+		}
+		if line.indent > first.indent {
+			return nil, p.errorf(line, "unexpected indentation")
+		}
+		if !isYAMLSeqItem(line.text) {
+			break
+		}
+
+		var v interface{}
+		var err error
+		rest := strings.TrimLeft(line.text[1:], " ")
+		switch {
+		case rest == "" || rest[0] == '#':
+			p.pos++
+			v, err = p.parseBlock(first.indent + 1)
+		case isYAMLBlockHeader(rest):
+			p.pos++
+			v, err = p.blockScalar(line, rest)
+		default:
+			// the item starts on the line of its dash: parse it as if it
+			// started a line of its own
+			p.lines[p.pos].indent = line.indent + len(line.text) - len(rest)
+			p.lines[p.pos].text = rest
+			v, err = p.parseBlock(p.lines[p.pos].indent)
+		}
+		if err != nil {
+			return nil, err
+		}
+		seq = append(seq, v)
+	}
+	return seq, nil
+}
+
+// parseMap parses the block mapping whose first key is on first
+func (p *yamlParser) parseMap(first yamlLine) (map[string]interface{}, error) {
+	m := map[string]interface{}{}
+	for {
+		line, ok := p.peek()
+		if !ok || line.indent < first.indent {
+			break
+		}
+		if line.indent > first.indent {
+			return nil, p.errorf(line, "unexpected indentation")
+		}
+		key, rest, ok, err := splitYAMLKey(line.text)
+		if err != nil {
+			return nil, p.errorf(line, "%v", err)
+		}
+		if !ok {
+			return nil, p.errorf(line, "expected a key, got %q", line.text)
+		}
+		if _, ok := m[key]; ok {
+			return nil, p.errorf(line, "key %s twice", key)
+		}
+		p.pos++
+
+		var v interface{}
+		switch {
+		case rest == "" || rest[0] == '#':
+			// a sequence may be indented as much as its key
+			next, ok := p.peek()
+			if ok && (next.indent > line.indent || next.indent == line.indent && isYAMLSeqItem(next.text)) {
+				v, err = p.parseBlock(next.indent)
+			}
+		case isYAMLBlockHeader(rest):
+			v, err = p.blockScalar(line, rest)
+		default:
+			if v, err = parseYAMLScalar(rest); err != nil {
+				err = p.errorf(line, "%v", err)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+	}
+	return m, nil
+}
+
+// blockScalar reads the literal (|) or folded (>) scalar below parent,
+// whose lines are indented more than parent
+func (p *yamlParser) blockScalar(parent yamlLine, header string) (string, error) {
+	if hash := strings.Index(header, "#"); hash >= 0 {
+		header = strings.TrimRight(header[:hash], " ")
+	}
+	style, chomp := header[0], header[1:]
+
+	var lines []string
+	indent := -1
+	for ; p.pos < len(p.lines); p.pos++ {
+		line := p.lines[p.pos]
+		if strings.TrimSpace(line.raw) == "" {
+			lines = append(lines, "")
+			continue
+		}
+		if indent < 0 {
+			if line.indent <= parent.indent {
+				break
+			}
+			indent = line.indent
+		}
+		if line.indent < indent {
+			break
+		}
+		lines = append(lines, line.raw[indent:])
+	}
+
+	end := len(lines)
+	for end > 0 && lines[end-1] == "" {
+		end--
+	}
+	trailing := len(lines) - end
+	lines = lines[:end]
+
+	var text string
+	if style == '|' {
+		text = strings.Join(lines, "\n")
+	} else {
+		text = foldYAML(lines)
+	}
+	if len(lines) > 0 && chomp != "-" {
+		text += "\n"
+	}
+	if chomp == "+" {
+		text += strings.Repeat("\n", trailing)
+	}
+	return text, nil
+}
+
+// foldYAML joins the lines of a folded scalar: line breaks between text
+// lines become spaces, empty lines become line breaks and more indented
+// lines keep theirs
+func foldYAML(lines []string) string {
+	var b strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			prev := lines[i-1]
+			folds := prev != "" && prev[0] != ' '
+			switch {
+			case folds && line == "":
+			case folds && line[0] != ' ':
+				b.WriteByte(' ')
+			default:
+				b.WriteByte('\n')
+			}
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+func isYAMLSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func isYAMLBlockHeader(text string) bool {
+	if hash := strings.Index(text, " #"); hash >= 0 {
+		text = strings.TrimRight(text[:hash], " ")
+	}
+	switch text {
+	case "|", "|-", "|+", ">", ">-", ">+":
+		return true
+	}
+	return false
+}
+
+// splitYAMLKey splits a "key: value" line. ok is false when the line is
+// no mapping entry.
+func splitYAMLKey(text string) (key, rest string, ok bool, err error) {
+	switch text[0] {
+	case '"', '\'':
+		key, n, err := readYAMLQuoted(text)
+		if err != nil {
+			return "", "", false, err
+		}
+		after := strings.TrimLeft(text[n:], " ")
+		if after == ":" || strings.HasPrefix(after, ": ") {
+			return key, strings.TrimSpace(after[1:]), true, nil
+		}
+		return "", "", false, nil
+	case '[', '{', '#', '|', '>':
+		return "", "", false, nil
+	}
+	if isYAMLSeqItem(text) {
+		return "", "", false, nil
+	}
+
+	colon := strings.Index(text, ": ")
+	if hash := strings.Index(text, " #"); hash >= 0 && (colon < 0 || hash < colon) {
+		text = strings.TrimRight(text[:hash], " ")
+		colon = -1
+	}
+	if colon < 0 {
+		if strings.HasSuffix(text, ":") {
+			return strings.TrimRight(text[:len(text)-1], " "), "", true, nil
+		}
+		return "", "", false, nil
+	}
+	return strings.TrimRight(text[:colon], " "), strings.TrimSpace(text[colon+2:]), true, nil
+}
+
+// parseYAMLScalar parses a value written on the line of its key or dash
+func parseYAMLScalar(text string) (interface{}, error) {
+	var v interface{}
+	var n int
+	var err error
+	switch text[0] {
+	case '"', '\'':
+		v, n, err = readYAMLQuoted(text)
+	case '[', '{':
+		flow := &yamlFlow{text: text}
+		v, err = flow.value()
+		n = flow.pos
+	case '&', '*', '!', '%', '@', '`', '|', '>':
+		return nil, fmt.Errorf("%q is not supported", text)
+	default:
+		if hash := strings.Index(text, " #"); hash >= 0 {
+			text = strings.TrimRight(text[:hash], " ")
+		}
+		return parseYAMLPlain(text)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if rest := strings.TrimLeft(text[n:], " "); rest != "" && rest[0] != '#' {
+		return nil, fmt.Errorf("unexpected %q after %s", rest, text[:n])
+	}
+	return v, nil
+}
+
+// parseYAMLPlain checks and types a plain scalar. Like YAML, it refuses
+// "a: b: c", where the value looks like a mapping of its own.
+func parseYAMLPlain(s string) (interface{}, error) {
+	if strings.Contains(s, ": ") || strings.HasSuffix(s, ":") {
+		return nil, fmt.Errorf("mapping values are not allowed in %q, quote it", s)
+	}
+	return resolveYAMLPlain(s), nil
+}
+
+// resolveYAMLPlain gives a plain scalar its type
+func resolveYAMLPlain(s string) interface{} {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+	if yamlInt.MatchString(s) {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n
+		}
+	}
+	if yamlFloat.MatchString(s) {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	}
+	return s
+}
+
+// readYAMLQuoted reads the quoted string text starts with and returns it
+// along with the number of bytes it took
+func readYAMLQuoted(text string) (string, int, error) {
+	if text[0] == '\'' {
+		var b strings.Builder
+		for i := 1; i < len(text); i++ {
+			if text[i] != '\'' {
+				b.WriteByte(text[i])
+			} else if i+1 < len(text) && text[i+1] == '\'' {
+				b.WriteByte('\'')
+				i++
+			} else {
+				return b.String(), i + 1, nil
+			}
+		}
+		return "", 0, errors.New("unterminated string")
+	}
+	for i := 1; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '"':
+			s, err := strconv.Unquote(text[:i+1])
+			if err != nil {
+				return "", 0, fmt.Errorf("invalid string %s", text[:i+1])
+			}
+			return s, i + 1, nil
+		}
+	}
+	return "", 0, errors.New("unterminated string")
+}
+
+// yamlFlow parses a flow collection such as {count: 100} or [a, b]
+type yamlFlow struct {
+	text string
+	pos  int
+}
+
+func (f *yamlFlow) peek() byte {
+	for f.pos < len(f.text) && f.text[f.pos] == ' ' {
+		f.pos++
+	}
+	if f.pos == len(f.text) {
+		return 0
+	}
+	return f.text[f.pos]
+}
+
+func (f *yamlFlow) value() (interface{}, error) {
+	switch f.peek() {
+	case 0:
+		return nil, errors.New("unterminated flow collection")
+	case '[':
+		f.pos++
+		seq := []interface{}{}
+		if f.peek() == ']' {
+			f.pos++
+			return seq, nil
+		}
+		for {
+			v, err := f.value()
+			if err != nil {
+				return nil, err
+			}
+			seq = append(seq, v)
+			if done, err := f.next(']'); err != nil || done {
+				return seq, err
+			}
+		}
+	case '{':
+		f.pos++
+		m := map[string]interface{}{}
+		if f.peek() == '}' {
+			f.pos++
+			return m, nil
+		}
+		for {
+			key, err := f.scalar(true)
+			if err != nil {
+				return nil, err
+			}
+			if f.peek() != ':' {
+				return nil, fmt.Errorf("expected : after %v in %s", key, f.text)
+			}
+			f.pos++
+			v, err := f.value()
+			if err != nil {
+				return nil, err
+			}
+			m[fmt.Sprint(key)] = v
+			if done, err := f.next('}'); err != nil || done {
+				return m, err
+			}
+		}
+	}
+	return f.scalar(false)
+}
+
+// next moves past the comma between entries or the end of the collection
+func (f *yamlFlow) next(end byte) (bool, error) {
+	switch f.peek() {
+	case ',':
+		f.pos++
+		return false, nil
+	case end:
+		f.pos++
+		return true, nil
+	}
+	return false, fmt.Errorf("expected , or %c in %s", end, f.text)
+}
+
+// scalar reads a quoted or plain scalar. Plain keys end at a colon.
+func (f *yamlFlow) scalar(key bool) (interface{}, error) {
+	if c := f.peek(); c == '"' || c == '\'' {
+		s, n, err := readYAMLQuoted(f.text[f.pos:])
+		f.pos += n
+		return s, err
+	}
+	start := f.pos
+	for f.pos < len(f.text) && !strings.ContainsRune(",[]{}", rune(f.text[f.pos])) && !(key && f.text[f.pos] == ':') {
+		f.pos++
+	}
+	plain := strings.TrimSpace(f.text[start:f.pos])
+	if key {
+		return plain, nil
+	}
+	return parseYAMLPlain(plain)
+}
@@ -0,0 +1,60 @@
+package goonep
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeYAML(t *testing.T) {
+	tests := []struct {
+		yaml     string
+		expected interface{}
+	}{
+		{"a: 1\nb: -2.5\nc: true\nd: ~\ne: text # comment\n", map[string]interface{}{"a": int64(1), "b": -2.5, "c": true, "d": nil, "e": "text"}},
+		{"---\n# only a comment\nkey: 'it''s' \nother: \"tab\\there\"\n", map[string]interface{}{"key": "it's", "other": "tab\there"}},
+		{"list:\n- a\n-   b\n- - nested\nnext: {x: [1, 'two'], y: \"a, b\"}\n", map[string]interface{}{
+			"list": []interface{}{"a", "b", []interface{}{"nested"}},
+			"next": map[string]interface{}{"x": []interface{}{int64(1), "two"}, "y": "a, b"},
+		}},
+		{"items:\n  - name: a\n    url: http://example.com/a\n  -\n    name: b\nempty:\n", map[string]interface{}{
+			"items": []interface{}{
+				map[string]interface{}{"name": "a", "url": "http://example.com/a"},
+				map[string]interface{}{"name": "b"},
+			},
+			"empty": nil,
+		}},
+		{"script: |\n  local a = 1 # not a comment\n\n  return a\nnext: 1\n", map[string]interface{}{"script": "local a = 1 # not a comment\n\nreturn a\n", "next": int64(1)}},
+		{"strip: |-\n  a\n\nkeep: |+\n  a\n\nfolded: >\n  a\n  b\n\n  c\n", map[string]interface{}{"strip": "a", "keep": "a\n\n", "folded": "a b\nc\n"}},
+		{"- |\n  text\n- 007\n- 0.5e1\n", []interface{}{"text\n", int64(7), 5.0}},
+		{"url: http://example.com:80/x\nquoted: 'b: c'\n", map[string]interface{}{"url": "http://example.com:80/x", "quoted": "b: c"}},
+	}
+	for _, test := range tests {
+		v, err := decodeYAML([]byte(test.yaml))
+		if err != nil {
+			t.Errorf("Failed: %q: %v", test.yaml, err)
+			continue
+		}
+		if !reflect.DeepEqual(v, test.expected) {
+			t.Errorf("Failed: %q decoded to %#v, expected %#v", test.yaml, v, test.expected)
+		}
+	}
+
+	invalid := []string{
+		"a: 1\na: 2\n",
+		"a:\n\tb: 1\n",
+		"a: 1\n  b: 2\n",
+		"a: &anchor 1\n",
+		"a: 'open\n",
+		"a: [1, 2\n",
+		"a: \"x\" y\n",
+		"- a\nb: 1\n",
+		"a: b: c\n",
+		"- a: b:\n",
+		"a: {b: c: d}\n",
+	}
+	for _, yaml := range invalid {
+		if v, err := decodeYAML([]byte(yaml)); err == nil {
+			t.Errorf("Failed: expected %q to be invalid, got %#v", yaml, v)
+		}
+	}
+}